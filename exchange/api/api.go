@@ -10,12 +10,27 @@ import (
 	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/bCoder778/log"
 	"strconv"
+	"strings"
+	sync2 "sync"
 )
 
 type Api struct {
 	rest         *RestApi
 	storage      *db.UTXODB
 	synchronizer *sync.Synchronizer
+
+	mutex    sync2.Mutex
+	rescans  []*rescanJob
+	rescanID int
+}
+
+// finished rescans are forgotten once there are more than this many
+const maxRescanJobs = 100
+
+type rescanJob struct {
+	id        int
+	addresses []string
+	rescan    *sync.Rescan
 }
 
 func NewApi(listen string, db *db.UTXODB, synchronizer *sync.Synchronizer) (*Api, error) {
@@ -44,6 +59,10 @@ func (a *Api) addApi() {
 	a.rest.AuthRouteSet("api/v1/address").Post(a.addAddress)
 	a.rest.AuthRouteSet("api/v1/address").Get(a.getAddress)
 	a.rest.AuthRouteSet("api/v1/address/utxo").Get(a.getAddressUTXO)
+	a.rest.AuthRouteSet("api/v1/node").Get(a.getNode)
+	a.rest.AuthRouteSet("api/v1/rescan").Post(a.startRescan)
+	a.rest.AuthRouteSet("api/v1/rescan").Get(a.getRescan)
+	a.rest.AuthRouteSet("api/v1/status").Get(a.getStatus)
 
 	a.rest.AuthRouteSet("api/v2/transaction").Post(a.sendTransactionV2)
 }
@@ -178,7 +197,7 @@ func (a *Api) sendTransaction(ct *Context) (interface{}, *Error) {
 	if err != nil {
 		return nil, &Error{ERROR_UNKNOWN, err.Error()}
 	}
-	txId, err := a.synchronizer.SendTx(raw)
+	txId, err := a.synchronizer.SendTxContext(ct.Context(), raw)
 	if err == nil {
 
 		for _, utxo := range utxoList {
@@ -191,7 +210,7 @@ func (a *Api) sendTransaction(ct *Context) (interface{}, *Error) {
 		}
 		a.storage.InsertSpentUTXO(spentUtxo)
 	} else {
-		return nil, &Error{ERROR_UNKNOWN, err.Error()}
+		return nil, NewError(err)
 	}
 	return txId, nil
 }
@@ -205,7 +224,7 @@ func (a *Api) sendTransactionV2(ct *Context) (interface{}, *Error) {
 	if err != nil {
 		return nil, &Error{ERROR_UNKNOWN, err.Error()}
 	}
-	txId, err := a.synchronizer.SendTx(raw)
+	txId, err := a.synchronizer.SendTxContext(ct.Context(), raw)
 	if err == nil {
 		decodeUtxoList := []*db.UTXO{}
 		for _, vin := range tx.TxIn {
@@ -227,7 +246,7 @@ func (a *Api) sendTransactionV2(ct *Context) (interface{}, *Error) {
 		}
 		a.storage.InsertSpentUTXO(spentUtxo)
 	} else {
-		return nil, &Error{ERROR_UNKNOWN, err.Error()}
+		return nil, NewError(err)
 	}
 	return txId, nil
 }
@@ -254,6 +273,9 @@ func (a *Api) addAddress(ct *Context) (interface{}, *Error) {
 	if !ok {
 		return nil, &Error{ERROR_UNKNOWN, "address is required"}
 	}
+	if err := a.synchronizer.CheckAddress(addr); err != nil {
+		return nil, &Error{ERROR_UNKNOWN, err.Error()}
+	}
 	err := a.storage.InsertAddress(addr)
 	if err != nil {
 		return nil, &Error{ERROR_UNKNOWN, err.Error()}
@@ -266,6 +288,106 @@ func (a *Api) getAddress(ct *Context) (interface{}, *Error) {
 	return addresses, nil
 }
 
+func (a *Api) getNode(ct *Context) (interface{}, *Error) {
+	rs := map[string]interface{}{
+		"node":    a.synchronizer.Node(),
+		"current": a.synchronizer.CurrentNode(),
+		"nodes":   a.synchronizer.Nodes(),
+	}
+	return rs, nil
+}
+
+func (a *Api) getStatus(ct *Context) (interface{}, *Error) {
+	return a.synchronizer.Status(), nil
+}
+
+// startRescan scans the synchronized blocks again for the utxos of added addresses
+func (a *Api) startRescan(ct *Context) (interface{}, *Error) {
+	address, _ := ct.Form["address"]
+	if len(address) == 0 {
+		return nil, &Error{ERROR_UNKNOWN, "address is required"}
+	}
+	addresses := strings.Split(address, ",")
+	for _, addr := range addresses {
+		if !a.storage.AddressIsExist(addr) {
+			return nil, &Error{ERROR_UNKNOWN, fmt.Sprintf("address %s is not added", addr)}
+		}
+	}
+	from, to := uint64(0), a.synchronizer.GetHistoryOrder().LastTxBlockOrder
+	var err error
+	if s, ok := ct.Form["from"]; ok && len(s) != 0 {
+		if from, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, &Error{ERROR_UNKNOWN, "wrong from"}
+		}
+	}
+	if s, ok := ct.Form["to"]; ok && len(s) != 0 {
+		if to, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, &Error{ERROR_UNKNOWN, "wrong to"}
+		}
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.pruneRescans()
+	if len(a.rescans) >= maxRescanJobs {
+		return nil, &Error{ERROR_UNKNOWN, "too many rescans running"}
+	}
+	rescan, err := a.synchronizer.Rescan(from, to, addresses)
+	if err != nil {
+		return nil, &Error{ERROR_UNKNOWN, err.Error()}
+	}
+	go func() {
+		for txs := range rescan.Txs() {
+			for i := range txs {
+				a.storage.IndexTransaction(&txs[i])
+			}
+		}
+		log.Infof("Rescan of %s done, %+v", address, rescan.Progress())
+	}()
+
+	job := &rescanJob{id: a.rescanID, addresses: addresses, rescan: rescan}
+	a.rescanID++
+	a.rescans = append(a.rescans, job)
+	return map[string]interface{}{
+		"id":       job.id,
+		"progress": rescan.Progress(),
+	}, nil
+}
+
+// pruneRescans forgets the oldest finished rescans while there are too many
+func (a *Api) pruneRescans() {
+	kept := a.rescans[:0]
+	drop := len(a.rescans) - maxRescanJobs + 1
+	for _, job := range a.rescans {
+		if drop > 0 && job.rescan.Progress().Done {
+			drop--
+			continue
+		}
+		kept = append(kept, job)
+	}
+	a.rescans = kept
+}
+
+// getRescan returns the progress of the rescan with the id, of all rescans without it
+func (a *Api) getRescan(ct *Context) (interface{}, *Error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	rs := []map[string]interface{}{}
+	for _, job := range a.rescans {
+		if s, ok := ct.Query["id"]; ok && s != strconv.Itoa(job.id) {
+			continue
+		}
+		rs = append(rs, map[string]interface{}{
+			"id":        job.id,
+			"addresses": job.addresses,
+			"progress":  job.rescan.Progress(),
+		})
+	}
+	return rs, nil
+}
+
 func (a *Api) getAddressUTXO(ct *Context) (interface{}, *Error) {
 	address := ct.Query["address"]
 	if len(address) == 0 {
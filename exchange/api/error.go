@@ -1,6 +1,10 @@
 package api
 
-import "strings"
+import (
+	"errors"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"strings"
+)
 
 const (
 	ERROR_UNKNOWN                     = 0x00000001
@@ -28,6 +32,27 @@ type Error struct {
 	Message string
 }
 
+// NewError classifies err, errors of the rpc node are mapped to their own codes
+func NewError(err error) *Error {
+	code := ERROR_UNKNOWN
+	var transportErr *rpc.TransportError
+	switch {
+	case errors.Is(err, rpc.ErrAlreadyInMempool):
+		code = ERROR_TRANSACTION_TXID_HAVE
+	case errors.Is(err, rpc.ErrDoubleSpend):
+		code = ERROR_TRANSACTION_TXID_USED
+	case errors.Is(err, rpc.ErrInsufficientFee):
+		code = ERROR_TRANSACTION_FEES_NOTENOUGH
+	case errors.Is(err, rpc.ErrTxNotFound):
+		code = ERROR_TRANSACTION_TXID_NOTEXIST
+	case errors.Is(err, rpc.ErrTxTooBig):
+		code = ERROR_TRANSACTION_RAWTX_TOOBIG
+	case errors.As(err, &transportErr):
+		code = ERROR_TRANSACTION_CONNECT_REFUSED
+	}
+	return &Error{code, err.Error()}
+}
+
 func (err *Error) DealError() *Result {
 	if err.Code == ERROR_UNKNOWN {
 		err.parseError()
@@ -35,21 +60,10 @@ func (err *Error) DealError() *Result {
 	return &Result{err.Code, err.Message, ""}
 }
 
+// parseError classifies the errors which are not from the rpc node, see NewError
 func (err *Error) parseError() {
-	if strings.Contains(err.Message, "already have transaction") {
-		err.Code = ERROR_TRANSACTION_TXID_HAVE
-	} else if strings.Contains(err.Message, "in the pool already spends the same coins") {
-		err.Code = ERROR_TRANSACTION_TXID_USED
-	} else if strings.Contains(err.Message, "connection refused") {
-		err.Code = ERROR_TRANSACTION_CONNECT_REFUSED
-	} else if strings.Contains(err.Message, "fees which is under the required amount of") {
-		err.Code = ERROR_TRANSACTION_FEES_NOTENOUGH
-	} else if strings.Contains(err.Message, "No information available about transaction") {
-		err.Code = ERROR_TRANSACTION_TXID_NOTEXIST
-	} else if strings.Contains(err.Message, "There is not enough balance") {
+	if strings.Contains(err.Message, "There is not enough balance") {
 		err.Code = ERROR_TOKEN_GET_NOTENOUGH
-	} else if strings.Contains(err.Message, "is larger than max allowed size of 100000") {
-		err.Code = ERROR_TRANSACTION_RAWTX_TOOBIG
 	} else {
 		err.Code = ERROR_UNKNOWN
 	}
@@ -139,6 +139,12 @@ func (r *RestSet) getRouterKey(method string, name string) string {
 	}
 	return fmt.Sprintf("%s-%s", method, r.Path+name)
 }
+
+// Context returns the context of the http request, it is canceled when the client goes away
+func (ct *Context) Context() context.Context {
+	return ct.request.Context()
+}
+
 func (ct *Context) initQuery() {
 	if ct.Query == nil {
 		ct.Query = map[string]string{}
@@ -41,6 +41,25 @@ type Rpc struct {
 	Tls      bool   `toml:"tls"`
 	Admin    string `toml:"admin"`
 	Password string `toml:"password"`
+	// backup nodes, reads fail over to them and transactions are sent to all
+	Hosts []string `toml:"hosts"`
+	// subscribe to the block notifications of the node over websocket
+	Notify bool `toml:"notify"`
+	// timeouts in seconds, 0 means no limit
+	ConnectTimeout uint64 `toml:"connect_timeout"`
+	ReadTimeout    uint64 `toml:"read_timeout"`
+	// tls verification, see rpc.RpcConfig
+	CA         string `toml:"ca"`
+	Cert       string `toml:"cert"`
+	Key        string `toml:"key"`
+	ServerName string `toml:"server_name"`
+	SkipVerify bool   `toml:"skip_verify"`
+	// requests per second and requests in flight toward the nodes, 0 means no limit
+	RateLimit   float64 `toml:"rate_limit"`
+	RateBurst   int     `toml:"rate_burst"`
+	MaxInFlight int     `toml:"max_in_flight"`
+	// file to record the rpc traffic to, see rpc.Recorder
+	Record string `toml:"record"`
 }
 
 type Sync struct {
@@ -48,6 +67,20 @@ type Sync struct {
 	Confirmations uint64   `toml:"confirmations"`
 	Address       []string `toml:"address"`
 	Log           *Log     `toml:"log"`
+	// network of the node, e.g. "mainnet" or "testnet"
+	Network string `toml:"network"`
+	// confirmed blocks fetched ahead during initial sync, 0 fetches one at a time
+	Prefetch int `toml:"prefetch"`
+	// recent blocks checked again for dag reorganizations, 0 disables it
+	ReorgWindow int `toml:"reorg_window"`
+	// seconds without a processed block before sync is reported stuck, 0 or less uses 10 minutes
+	StuckTimeout int `toml:"stuck_timeout"`
+	// oldest node build version to sync from, empty accepts any
+	MinNodeVersion string `toml:"min_node_version"`
+	// retry policy of the rpc calls and the sync loop, 0 uses the default
+	RetryMaxAttempts  int    `toml:"retry_max_attempts"`
+	RetryMinBackoffMs uint64 `toml:"retry_min_backoff_ms"`
+	RetryMaxBackoffMs uint64 `toml:"retry_max_backoff_ms"`
 }
 
 type Log struct {
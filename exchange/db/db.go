@@ -19,6 +19,8 @@ const (
 	result_bucket         = "result_bucket"
 	address_bucket        = "address_bucket"
 	height_bucket         = "height_bucket"
+
+	last_block_hash = "last_block_hash"
 )
 
 type UTXODB struct {
@@ -62,6 +64,17 @@ func (c *UTXODB) LastBlockOrder() uint64 {
 	return encode.BytesToUint64(bytes)
 }
 
+func (c *UTXODB) LastBlockHash() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	bytes, err := c.base.GetFromBucket(block_bucket, []byte(last_block_hash))
+	if err != nil {
+		return ""
+	}
+	return string(bytes)
+}
+
 func (c *UTXODB) LastCoinBaseBlockOrder() uint64 {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
@@ -129,6 +142,13 @@ func (c *UTXODB) UpdateLastOrder(order uint64) {
 	c.base.PutInBucket(block_bucket, []byte(block_bucket), encode.Uint64ToBytes(order))
 }
 
+func (c *UTXODB) UpdateLastBlockHash(hash string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.base.PutInBucket(block_bucket, []byte(last_block_hash), []byte(hash))
+}
+
 func (c *UTXODB) UpdateCoinBaseLastOrder(order uint64) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"encoding/json"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/uxto"
+)
+
+// SaveTransaction saves the outputs of tx paying to the synchronized
+// addresses and marks the outputs it spends as spent
+func (c *UTXODB) SaveTransaction(tx *rpc.Transaction) {
+	c.UpdateHeight(tx.BlockHeight)
+	c.IndexTransaction(tx)
+}
+
+// IndexTransaction saves tx like SaveTransaction without moving the chain height,
+// for the transactions of old blocks found by a rescan
+func (c *UTXODB) IndexTransaction(tx *rpc.Transaction) {
+	// save tx or uxto
+	utxos := uxto.GetUxtos(tx)
+	for _, u := range utxos {
+		if c.AddressIsExist(u.Address) {
+			dbUtxo := &UTXO{
+				TxId:       u.TxId,
+				Vout:       uint64(u.TxIndex),
+				Address:    u.Address,
+				Amount:     u.Amount,
+				Coin:       u.Coin,
+				Height:     u.Height,
+				IsCoinBase: tx.IsCoinBase,
+				PkHex:      u.PkHex,
+			}
+			c.UpdateAddressUTXO(u.Address, dbUtxo)
+			c.SaveUTXO(dbUtxo)
+		}
+	}
+	// the spent outputs are saved ones whatever tx pays to, e.g. a withdrawal
+	// to a foreign address found by a rescan
+	spentTxs := uxto.GetSpentTxs(tx)
+	for _, spentTx := range spentTxs {
+		u, err := c.GetUTXO(spentTx.TxId, spentTx.Vout)
+		if err != nil {
+			continue
+		}
+		// 标记这些utxo已经被花费掉
+		c.UpdateAddressUTXO(u.Address, &UTXO{
+			TxId:   u.TxId,
+			Coin:   u.Coin,
+			Vout:   u.Vout,
+			Amount: u.Amount,
+			Spent:  tx.Txid,
+		})
+	}
+}
+
+// RollbackTransaction undoes SaveTransaction. The outputs of tx are deleted and the
+// outputs it spent become unspent again, unless tx is a withdrawal sent through the
+// api which is still waiting to be mined again.
+func (c *UTXODB) RollbackTransaction(tx *rpc.Transaction) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, u := range uxto.GetUxtos(tx) {
+		key := []byte(getOutKey(u.TxId, uint64(u.TxIndex)))
+		c.base.DeleteFromBucket(getUTXOBucket(u.Address), key)
+		c.base.DeleteFromBucket(utxo_bucket, key)
+	}
+	if spent, _ := c.base.GetFromBucket(spent_bucket, []byte(tx.Txid)); spent != nil {
+		return
+	}
+	for _, spentTx := range uxto.GetSpentTxs(tx) {
+		bytes, err := c.base.GetFromBucket(utxo_bucket, []byte(getOutKey(spentTx.TxId, spentTx.Vout)))
+		if err != nil {
+			continue
+		}
+		var u *UTXO
+		if err := json.Unmarshal(bytes, &u); err != nil {
+			continue
+		}
+		addrUtxo, err := c.getAddressUTXO(u.Address, u.TxId, u.Vout)
+		if err != nil || addrUtxo.Spent != tx.Txid {
+			continue
+		}
+		addrUtxo.Spent = ""
+		c.saveAddressUTXO(u.Address, addrUtxo)
+	}
+}
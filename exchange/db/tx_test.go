@@ -0,0 +1,74 @@
+package db
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	sync2 "github.com/Qitmeer/exchange-lib/sync"
+	"testing"
+	"time"
+)
+
+func TestUTXODB_Rescan(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmNew", 100)})
+	node.MineBlock(deposit)
+	// the withdrawal pays to foreign addresses only
+	node.MineBlock(rpctest.NewTransaction([]rpc.Vin{rpctest.Input(deposit.Txid, 0)}, []rpc.Vout{rpctest.Output("TmOther", 90)}))
+	kept := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmNew", 50)})
+	node.MineBlock(kept)
+	node.Mine(3)
+
+	synchronizer := sync2.NewSynchronizer(&sync2.Options{
+		RpcAddr:      node.Addr(),
+		RpcUser:      "test",
+		RpcPwd:       "test",
+		PollInterval: 10 * time.Millisecond,
+	})
+	if _, err := synchronizer.Start(&sync2.HistoryOrder{Confirmations: 1}); err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	timeout := time.After(5 * time.Second)
+	for synchronizer.GetHistoryOrder().LastTxBlockOrder < 4 {
+		select {
+		case <-timeout:
+			t.Fatal("the blocks were not synchronized")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	storage, err := NewUTXODB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	storage.InsertAddress("TmNew")
+	rescan, err := synchronizer.Rescan(0, 4, []string{"TmNew"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for txs := range rescan.Txs() {
+		for i := range txs {
+			storage.IndexTransaction(&txs[i])
+		}
+	}
+	if err := rescan.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	utxos, sum, err := storage.GetAddressUTXOs("TmNew", "MEER", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != 50 || len(utxos) != 1 || utxos[0].TxId != kept.Txid {
+		t.Fatalf("expected the unspent deposit only, got %d in %+v", sum, utxos)
+	}
+	spent, _, err := storage.GetAddressSpentUTXOs("TmNew", "MEER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spent) != 1 || spent[0].TxId != deposit.Txid {
+		t.Fatalf("expected the withdrawn deposit to be spent, got %+v", spent)
+	}
+}
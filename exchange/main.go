@@ -1,18 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/Qitmeer/exchange-lib/exchange/api"
 	"github.com/Qitmeer/exchange-lib/exchange/conf"
 	"github.com/Qitmeer/exchange-lib/exchange/db"
 	"github.com/Qitmeer/exchange-lib/exchange/version"
+	"github.com/Qitmeer/exchange-lib/rpc"
 	"github.com/Qitmeer/exchange-lib/sync"
-	"github.com/Qitmeer/exchange-lib/uxto"
 	"github.com/bCoder778/log"
 	"os"
 	"os/signal"
-	"strings"
 	sync2 "sync"
 	"time"
 )
@@ -38,6 +39,34 @@ func main() {
 		RpcPwd:  conf.Setting.Rpc.Password,
 		Https:   conf.Setting.Rpc.Tls,
 		TxChLen: 100,
+
+		RpcAddrs:          conf.Setting.Rpc.Hosts,
+		RpcConnectTimeout: time.Duration(conf.Setting.Rpc.ConnectTimeout) * time.Second,
+		RpcReadTimeout:    time.Duration(conf.Setting.Rpc.ReadTimeout) * time.Second,
+		RpcCAFile:         conf.Setting.Rpc.CA,
+		RpcCertFile:       conf.Setting.Rpc.Cert,
+		RpcKeyFile:        conf.Setting.Rpc.Key,
+		RpcServerName:     conf.Setting.Rpc.ServerName,
+		RpcSkipVerify:     conf.Setting.Rpc.SkipVerify,
+		Retry:             retryPolicy(conf.Setting.Sync),
+		Notify:            conf.Setting.Rpc.Notify,
+		RpcRateLimit:      conf.Setting.Rpc.RateLimit,
+		RpcRateBurst:      conf.Setting.Rpc.RateBurst,
+		RpcMaxInFlight:    conf.Setting.Rpc.MaxInFlight,
+		Network:           conf.Setting.Sync.Network,
+		PrefetchWorkers:   conf.Setting.Sync.Prefetch,
+		ReorgWindow:       conf.Setting.Sync.ReorgWindow,
+		StuckTimeout:      time.Duration(conf.Setting.Sync.StuckTimeout) * time.Second,
+		MinNodeVersion:    conf.Setting.Sync.MinNodeVersion,
+	}
+	if conf.Setting.Rpc.Record != "" {
+		recorder, err := rpc.OpenRecorder(conf.Setting.Rpc.Record)
+		if err != nil {
+			fmt.Println("failed to open rpc record, ", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		opt.RpcWrapTransport = recorder.Wrap
 	}
 	synchronizer := sync.NewSynchronizer(opt)
 	listenInterrupt()
@@ -72,19 +101,25 @@ func startSync(storage *db.UTXODB, synchronizer *sync.Synchronizer, wg *sync2.Wa
 	defer wg.Done()
 
 	for _, addr := range conf.Setting.Sync.Address {
+		if err := synchronizer.CheckAddress(addr); err != nil {
+			log.Errorf("Failed to start sync block, %s", err.Error())
+			return
+		}
 		storage.InsertAddress(addr)
 	}
 
 	start := conf.Setting.Sync.Start
+	lastHash := ""
 	lastOrder := storage.LastBlockOrder()
 	if lastOrder != 0 {
 		start = lastOrder
+		lastHash = storage.LastBlockHash()
 	}
 
-
-	txChan, err := synchronizer.Start(&sync.HistoryOrder{
-		LastTxBlockOrder:       start,
-		Confirmations:          conf.Setting.Sync.Confirmations,
+	events, err := synchronizer.StartEvents(&sync.HistoryOrder{
+		LastTxBlockOrder: start,
+		Confirmations:    conf.Setting.Sync.Confirmations,
+		LastBlockHash:    lastHash,
 	})
 	if err != nil {
 		log.Errorf("Failed to start sync block, %s", err.Error())
@@ -92,68 +127,57 @@ func startSync(storage *db.UTXODB, synchronizer *sync.Synchronizer, wg *sync2.Wa
 	}
 
 	go dealSpent(storage, synchronizer)
+	go dealErrors(synchronizer)
 
-	var preOrder uint64
 	go func() {
-		for {
-			select {
-			case <-interrupt:
-				synchronizer.Stop()
-				log.Infof("Stop sync block")
-				return
-			default:
-
-				txs := <-txChan
-				for _, tx := range txs {
-					storage.UpdateHeight(tx.BlockHeight)
-					utxoFlag := false
-					// save tx or uxto
-					utxos := uxto.GetUxtos(&tx)
-					for _, u := range utxos {
-						if storage.AddressIsExist(u.Address) {
-							utxoFlag = true
-							dbUtxo := &db.UTXO{
-								TxId:       u.TxId,
-								Vout:       uint64(u.TxIndex),
-								Address:    u.Address,
-								Amount:     u.Amount,
-								Coin:       u.Coin,
-								Height:     u.Height,
-								IsCoinBase: tx.IsCoinBase,
-								PkHex: 		u.PkHex,
-							}
-							storage.UpdateAddressUTXO(u.Address, dbUtxo)
-							storage.SaveUTXO(dbUtxo)
-						}
-					}
-					if utxoFlag {
-						spentTxs := uxto.GetSpentTxs(&tx)
-						for _, spentTx := range spentTxs {
-							u, err := storage.GetUTXO(spentTx.TxId, spentTx.Vout)
-							if err != nil {
-								continue
-							}
-							// 标记这些utxo已经被花费掉
-							storage.UpdateAddressUTXO(u.Address, &db.UTXO{
-								TxId:   u.TxId,
-								Coin:   u.Coin,
-								Vout:   u.Vout,
-								Amount: u.Amount,
-								Spent:  tx.Txid,
-							})
-						}
-					}
+		<-interrupt
+		history := synchronizer.Stop()
+		log.Infof("Stop sync block at order %d", history.LastTxBlockOrder)
+	}()
 
-					if preOrder != tx.BlockOrder {
-						preOrder = tx.BlockOrder
-						storage.UpdateLastOrder(preOrder)
-						log.Infof("Sync tx block order %d", preOrder)
-					}
-				}
-			}
+	// the events left are saved after the interrupt, the channel is closed then
+	for ev := range events {
+		if ev.Rollback {
+			rollbackBlock(storage, ev)
+		} else {
+			saveBlock(storage, ev)
+		}
+	}
+}
+
+func saveBlock(storage *db.UTXODB, ev *sync.BlockEvent) {
+	for i := range ev.Txs {
+		storage.SaveTransaction(&ev.Txs[i])
+	}
+	storage.UpdateHeight(ev.Height)
+	storage.UpdateLastOrder(ev.Order)
+	storage.UpdateLastBlockHash(ev.Hash)
+	if len(ev.Txs) != 0 {
+		log.Infof("Sync tx block order %d", ev.Order)
+	}
+}
 
+func rollbackBlock(storage *db.UTXODB, ev *sync.BlockEvent) {
+	for i := len(ev.Txs) - 1; i >= 0; i-- {
+		storage.RollbackTransaction(&ev.Txs[i])
+	}
+	var order uint64
+	if ev.Order > 0 {
+		order = ev.Order - 1
+	}
+	storage.UpdateLastOrder(order)
+	storage.UpdateLastBlockHash("")
+	log.Infof("Roll back block %s order %d, %s", ev.Hash, ev.Order, ev.Reason)
+}
+
+func dealErrors(synchronizer *sync.Synchronizer) {
+	for err := range synchronizer.Errors() {
+		if err.Kind == sync.ErrorStuck {
+			log.Errorf("Sync block is stuck, %s", err.Error())
+		} else if !err.Warning {
+			log.Warnf("Failed to sync block, %s", err.Error())
 		}
-	}()
+	}
 }
 
 func dealSpent(storage *db.UTXODB, synchronizer *sync.Synchronizer) {
@@ -166,10 +190,12 @@ func dealSpent(storage *db.UTXODB, synchronizer *sync.Synchronizer) {
 			log.Infof("Stop deal spent")
 			return
 		case <-t.C:
+			// reconciliation must not slow down sync and withdrawals
+			ctx := rpc.WithPriority(context.Background(), rpc.PriorityBackground)
 			spents := storage.GetSpents()
 			for _, spent := range spents {
-				_, err := synchronizer.GetTx(spent.SpentTxId)
-				if err != nil && isNoTx(err)  {
+				_, err := synchronizer.GetTxContext(ctx, spent.SpentTxId)
+				if errors.Is(err, rpc.ErrTxNotFound) {
 					log.Debugf("could not found tx %s", spent.SpentTxId)
 					for _, utxo := range spent.UTXOList {
 						utxo.Spent = ""
@@ -185,11 +211,18 @@ func dealSpent(storage *db.UTXODB, synchronizer *sync.Synchronizer) {
 	}
 }
 
-func isNoTx(err error) bool {
-	if strings.Contains(err.Error(), "No information available about transaction") {
-		return true
+func retryPolicy(cfg *conf.Sync) *rpc.RetryPolicy {
+	policy := sync.DefaultRetryPolicy()
+	if cfg.RetryMaxAttempts != 0 {
+		policy.MaxAttempts = cfg.RetryMaxAttempts
+	}
+	if cfg.RetryMinBackoffMs != 0 {
+		policy.InitialBackoff = time.Duration(cfg.RetryMinBackoffMs) * time.Millisecond
+	}
+	if cfg.RetryMaxBackoffMs != 0 {
+		policy.MaxBackoff = time.Duration(cfg.RetryMaxBackoffMs) * time.Millisecond
 	}
-	return false
+	return policy
 }
 
 func startApi(db *db.UTXODB, synchronizer *sync.Synchronizer, wg *sync2.WaitGroup) {
@@ -218,7 +251,7 @@ func openDB(path string) (*db.UTXODB, error) {
 
 func listenInterrupt() {
 	interrupt = make(chan struct{}, 1)
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, os.Kill)
 
 	go func() {
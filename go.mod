@@ -8,5 +8,6 @@ require (
 	github.com/bCoder778/log v0.1.3 // indirect
 	github.com/btcsuite/goleveldb v1.0.0 // indirect
 	github.com/go-gomail/gomail v0.0.0-20160411212932-81ebce5c23df // indirect
+	github.com/gorilla/websocket v1.4.2
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 )
@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// BatchCall sends all requests to the node in a single json-rpc 2.0 batch.
+// The ids of the requests are overwritten with their position in reqs, and the
+// responses are returned in the same order as reqs whatever order the node
+// answers in. A request the node did not answer gets a response with an error.
+func (c *Client) BatchCall(ctx context.Context, reqs []*ClientRequest) ([]*ClientResponse, error) {
+	if len(reqs) == 0 {
+		return []*ClientResponse{}, nil
+	}
+	methods := make([]string, 0, len(reqs))
+	for i, req := range reqs {
+		req.Id = i + 1
+		methods = append(methods, req.Method)
+	}
+	bodyBytes, err := c.postRetry(ctx, reqs, methods...)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := []*ClientResponse{}
+	if err := json.Unmarshal(bodyBytes, &rs); err != nil {
+		// a node rejecting the whole batch answers with a single response
+		single := &ClientResponse{}
+		if json.Unmarshal(bodyBytes, single) == nil && single.Error != nil {
+			return nil, single.Error
+		}
+		return nil, fmt.Errorf("json unmarshal failed; value:%s; error:%s", string(bodyBytes), err.Error())
+	}
+
+	resps := make([]*ClientResponse, len(reqs))
+	for _, resp := range rs {
+		idx, ok := responseIndex(resp.ID, len(reqs))
+		if !ok {
+			continue
+		}
+		if resp.Error != nil {
+			resp.Error.Method = reqs[idx].Method
+		}
+		resps[idx] = resp
+	}
+	for i, resp := range resps {
+		if resp == nil {
+			resps[i] = &ClientResponse{ID: i + 1, Error: &Error{Message: "no response in batch", Method: reqs[i].Method}}
+		}
+	}
+	return resps, nil
+}
+
+// responseIndex maps the id of a batch response back to the position of its request
+func responseIndex(id interface{}, size int) (int, bool) {
+	var n int
+	switch v := id.(type) {
+	case float64:
+		n = int(v)
+	case string:
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, false
+		}
+		n = i
+	default:
+		return 0, false
+	}
+	if n < 1 || n > size {
+		return 0, false
+	}
+	return n - 1, true
+}
+
+func (c *Client) GetBlocksByOrderRange(from, to uint64) ([]*Block, error) {
+	return c.GetBlocksByOrderRangeContext(context.Background(), from, to)
+}
+
+// GetBlocksByOrderRangeContext fetches the blocks with order in [from, to] in one batch.
+// The result stops before the first order the node could not return, so asking past
+// the tip returns the available blocks. An error is returned if not even the block at
+// from could be fetched.
+func (c *Client) GetBlocksByOrderRangeContext(ctx context.Context, from, to uint64) ([]*Block, error) {
+	return c.getBlocksByOrderRange(ctx, from, to, []interface{}{true})
+}
+
+func (c *Client) GetBlocksByOrderRangeNoTx(from, to uint64) ([]*Block, error) {
+	return c.GetBlocksByOrderRangeNoTxContext(context.Background(), from, to)
+}
+
+// GetBlocksByOrderRangeNoTxContext fetches the blocks like GetBlocksByOrderRangeContext
+// without their transactions, e.g. to compare hashes, validity and confirmations
+func (c *Client) GetBlocksByOrderRangeNoTxContext(ctx context.Context, from, to uint64) ([]*Block, error) {
+	return c.getBlocksByOrderRange(ctx, from, to, []interface{}{true, false})
+}
+
+// getBlocksByOrderRange sends getBlockByOrder for every order with the params after the order
+func (c *Client) getBlocksByOrderRange(ctx context.Context, from, to uint64, params []interface{}) ([]*Block, error) {
+	if to < from {
+		return nil, fmt.Errorf("invalid block order range [%d, %d]", from, to)
+	}
+	reqs := make([]*ClientRequest, 0, to-from+1)
+	for order := from; order <= to; order++ {
+		reqs = append(reqs, NewReqeust(append([]interface{}{order}, params...)).SetMethod("getBlockByOrder"))
+	}
+	resps, err := c.BatchCall(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+	blocks := make([]*Block, 0, len(resps))
+	for _, resp := range resps {
+		if resp.Error != nil {
+			if len(blocks) == 0 {
+				return nil, resp.Error
+			}
+			break
+		}
+		blk := new(Block)
+		if err := json.Unmarshal(resp.Result, blk); err != nil {
+			if len(blocks) == 0 {
+				return nil, errors.New("failed to parse response json")
+			}
+			break
+		}
+		blocks = append(blocks, blk)
+	}
+	return blocks, nil
+}
+
+func (c *Client) IsBlueBatch(hashes []string) ([]int, error) {
+	return c.IsBlueBatchContext(context.Background(), hashes)
+}
+
+// IsBlueBatchContext returns the color of every block in hashes, in the same order
+func (c *Client) IsBlueBatchContext(ctx context.Context, hashes []string) ([]int, error) {
+	reqs := make([]*ClientRequest, 0, len(hashes))
+	for _, hash := range hashes {
+		reqs = append(reqs, NewReqeust([]interface{}{hash}).SetMethod("isBlue"))
+	}
+	resps, err := c.BatchCall(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]int, 0, len(resps))
+	for i, resp := range resps {
+		if resp.Error != nil {
+			return nil, fmt.Errorf("block %s: %w", hashes[i], resp.Error)
+		}
+		state, err := strconv.Atoi(string(resp.Result))
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestClient_BatchCall(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqs []ClientRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			t.Fatal(err)
+		}
+		// answer in reverse order, the client has to correlate by id
+		var resps []string
+		for i := len(reqs) - 1; i >= 0; i-- {
+			order := reqs[i].Params[0].(float64)
+			if order > 2 {
+				resps = append(resps, fmt.Sprintf(`{"id":%v,"error":{"code":-5,"message":"not found"}}`, reqs[i].Id))
+				continue
+			}
+			resps = append(resps, fmt.Sprintf(`{"id":%v,"result":{"hash":"h%v","order":%v}}`, reqs[i].Id, order, order))
+		}
+		w.Write([]byte("["))
+		for i, resp := range resps {
+			if i > 0 {
+				w.Write([]byte(","))
+			}
+			w.Write([]byte(resp))
+		}
+		w.Write([]byte("]"))
+	})
+
+	blocks, err := client.GetBlocksByOrderRange(0, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %d", len(blocks))
+	}
+	for i, blk := range blocks {
+		if blk.Order != uint64(i) || blk.Hash != fmt.Sprintf("h%d", i) {
+			t.Fatalf("unexpected block %d: %+v", i, blk)
+		}
+	}
+
+	if _, err := client.GetBlocksByOrderRange(3, 5); err == nil {
+		t.Fatalf("expected error when the first block is missing")
+	}
+}
+
+func TestClient_BlocksWithoutTxs(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqs []ClientRequest
+		if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("["))
+		for i, req := range reqs {
+			if len(req.Params) != 3 || req.Params[1] != true || req.Params[2] != false {
+				t.Errorf("expected a verbose block without transactions, got %v", req.Params)
+			}
+			if i > 0 {
+				w.Write([]byte(","))
+			}
+			fmt.Fprintf(w, `{"id":%v,"result":{"hash":"h%v","order":%v}}`, req.Id, req.Params[0], req.Params[0])
+		}
+		w.Write([]byte("]"))
+	})
+
+	blocks, err := client.GetBlocksByOrderRangeNoTx(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 || blocks[0].Hash != "h1" {
+		t.Fatalf("unexpected blocks %+v", blocks)
+	}
+}
@@ -83,7 +83,61 @@ type GraphState struct {
 }
 
 type NodeInfo struct {
+	Version          int    `json:"version"`
+	BuildVersion     string `json:"buildversion"`
+	ProtocolVersion  int32  `json:"protocolversion"`
+	Network          string `json:"network"`
 	Confirmations    uint32 `json:"confirmations"`
 	Coinbasematurity uint32 `json:"coinbasematurity"`
 	GraphState       `json:"graphstate"`
 }
+
+type BlockHeader struct {
+	Hash          string    `json:"hash"`
+	Confirmations uint32    `json:"confirmations"`
+	Version       uint32    `json:"version"`
+	ParentRoot    string    `json:"parentroot"`
+	TxRoot        string    `json:"txRoot"`
+	StateRoot     string    `json:"stateroot"`
+	Difficulty    uint64    `json:"difficulty"`
+	Layer         uint64    `json:"layer"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// TxOut is an unspent transaction output
+type TxOut struct {
+	BestBlock     string       `json:"bestblock"`
+	Confirmations uint32       `json:"confirmations"`
+	CoinId        uint16       `json:"coinId"`
+	Amount        uint64       `json:"amount"`
+	ScriptPubKey  ScriptPubKey `json:"scriptPubKey"`
+	Coinbase      bool         `json:"coinbase"`
+}
+
+type MempoolEntry struct {
+	Size    int32    `json:"size"`
+	Fee     uint64   `json:"fee"`
+	Time    int64    `json:"time"`
+	Height  uint64   `json:"height"`
+	Depends []string `json:"depends"`
+}
+
+type PeerInfo struct {
+	ID         string      `json:"id"`
+	Address    string      `json:"address"`
+	State      bool        `json:"state"`
+	Protocol   uint32      `json:"protocol"`
+	Services   string      `json:"services"`
+	Direction  string      `json:"direction"`
+	SyncNode   bool        `json:"syncnode"`
+	TimeOffset int64       `json:"timeoffset"`
+	Version    string      `json:"version"`
+	GraphState *GraphState `json:"graphstate"`
+}
+
+type NetworkInfo struct {
+	Peers      int    `json:"peers"`
+	Connected  int    `json:"connected"`
+	Network    string `json:"network"`
+	MaxConnect int    `json:"maxconnect"`
+}
@@ -0,0 +1,167 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+func (c *Client) GetBlockHeader(hash string) (*BlockHeader, error) {
+	return c.GetBlockHeaderContext(context.Background(), hash)
+}
+
+func (c *Client) GetBlockHeaderContext(ctx context.Context, hash string) (*BlockHeader, error) {
+	params := []interface{}{hash, true}
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getBlockHeader"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	header := new(BlockHeader)
+	if err := json.Unmarshal(resp.Result, header); err != nil {
+		return nil, errors.New("failed to parse response json")
+	}
+	return header, nil
+}
+
+// GetBlockHash returns the hash of the block at order
+func (c *Client) GetBlockHash(order uint64) (string, error) {
+	return c.GetBlockHashContext(context.Background(), order)
+}
+
+func (c *Client) GetBlockHashContext(ctx context.Context, order uint64) (string, error) {
+	params := []interface{}{order}
+	return c.callString(ctx, NewReqeust(params).SetMethod("getBlockhash"))
+}
+
+// GetBestBlockHash returns the hash of the tip of the main chain
+func (c *Client) GetBestBlockHash() (string, error) {
+	return c.GetBestBlockHashContext(context.Background())
+}
+
+func (c *Client) GetBestBlockHashContext(ctx context.Context) (string, error) {
+	params := []interface{}{}
+	return c.callString(ctx, NewReqeust(params).SetMethod("getBestBlockHash"))
+}
+
+// GetUtxo returns output vout of txid, nil if it is spent or does not exist.
+// Outputs spent in the mempool count as spent when includeMempool is true.
+func (c *Client) GetUtxo(txId string, vout uint32, includeMempool bool) (*TxOut, error) {
+	return c.GetUtxoContext(context.Background(), txId, vout, includeMempool)
+}
+
+func (c *Client) GetUtxoContext(ctx context.Context, txId string, vout uint32, includeMempool bool) (*TxOut, error) {
+	params := []interface{}{txId, vout, includeMempool}
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getUtxo"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	var out *TxOut
+	if err := json.Unmarshal(resp.Result, &out); err != nil {
+		return nil, errors.New("failed to parse response json")
+	}
+	return out, nil
+}
+
+// EstimateFee returns the fee per kilobyte in atoms for a transaction
+// to be confirmed within numBlocks blocks
+func (c *Client) EstimateFee(numBlocks uint32) (uint64, error) {
+	return c.EstimateFeeContext(context.Background(), numBlocks)
+}
+
+func (c *Client) EstimateFeeContext(ctx context.Context, numBlocks uint32) (uint64, error) {
+	params := []interface{}{numBlocks}
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("estimateFee"))
+	if err != nil {
+		return 0, err
+	}
+	if resp.Error != nil {
+		return 0, resp.Error
+	}
+	var fee uint64
+	if err := json.Unmarshal(resp.Result, &fee); err != nil {
+		return 0, errors.New("failed to parse response json")
+	}
+	return fee, nil
+}
+
+// GetMemoryPoolVerbose returns the mempool entries by txid
+func (c *Client) GetMemoryPoolVerbose() (map[string]*MempoolEntry, error) {
+	return c.GetMemoryPoolVerboseContext(context.Background())
+}
+
+func (c *Client) GetMemoryPoolVerboseContext(ctx context.Context) (map[string]*MempoolEntry, error) {
+	params := []interface{}{"", true}
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getMempool"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	rs := map[string]*MempoolEntry{}
+	if err := json.Unmarshal(resp.Result, &rs); err != nil {
+		return nil, errors.New("failed to parse response json")
+	}
+	return rs, nil
+}
+
+func (c *Client) GetPeerInfo() ([]*PeerInfo, error) {
+	return c.GetPeerInfoContext(context.Background())
+}
+
+func (c *Client) GetPeerInfoContext(ctx context.Context) ([]*PeerInfo, error) {
+	params := []interface{}{}
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getPeerInfo"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	rs := []*PeerInfo{}
+	if err := json.Unmarshal(resp.Result, &rs); err != nil {
+		return nil, errors.New("failed to parse response json")
+	}
+	return rs, nil
+}
+
+func (c *Client) GetNetworkInfo() (*NetworkInfo, error) {
+	return c.GetNetworkInfoContext(context.Background())
+}
+
+func (c *Client) GetNetworkInfoContext(ctx context.Context) (*NetworkInfo, error) {
+	params := []interface{}{}
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getNetworkInfo"))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	info := new(NetworkInfo)
+	if err := json.Unmarshal(resp.Result, info); err != nil {
+		return nil, errors.New("failed to parse response json")
+	}
+	return info, nil
+}
+
+func (c *Client) callString(ctx context.Context, req *ClientRequest) (string, error) {
+	resp, err := c.call(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+	var rs string
+	if err := json.Unmarshal(resp.Result, &rs); err != nil {
+		return "", errors.New("failed to parse response json")
+	}
+	return rs, nil
+}
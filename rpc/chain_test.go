@@ -0,0 +1,78 @@
+package rpc_test
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+)
+
+func TestClient_ChainQueries(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	client := rpc.NewClient(node.Config())
+	defer client.Close()
+
+	blocks := node.Mine(3)
+	tip := blocks[len(blocks)-1]
+
+	best, err := client.GetBestBlockHash()
+	if err != nil || best != tip.Hash {
+		t.Fatalf("best block hash %s, %v, want %s", best, err, tip.Hash)
+	}
+	hash, err := client.GetBlockHash(1)
+	if err != nil || hash != blocks[0].Hash {
+		t.Fatalf("block hash %s, %v, want %s", hash, err, blocks[0].Hash)
+	}
+	header, err := client.GetBlockHeader(blocks[0].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Hash != blocks[0].Hash || header.Confirmations != 2 {
+		t.Fatalf("unexpected header %+v", header)
+	}
+	if fee, err := client.EstimateFee(6); err != nil || fee != node.FeeRate {
+		t.Fatalf("fee %d, %v, want %d", fee, err, node.FeeRate)
+	}
+	if peers, err := client.GetPeerInfo(); err != nil || len(peers) != 0 {
+		t.Fatalf("peers %v, %v", peers, err)
+	}
+}
+
+func TestClient_GetUtxo(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	client := rpc.NewClient(node.Config())
+	defer client.Close()
+
+	funding := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)})
+	node.MineBlock(funding)
+
+	out, err := client.GetUtxo(funding.Txid, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == nil || out.Amount != 100 {
+		t.Fatalf("unexpected utxo %+v", out)
+	}
+
+	spend := rpctest.NewTransaction([]rpc.Vin{rpctest.Input(funding.Txid, 0)}, nil)
+	node.AddToMempool(spend)
+	if out, err := client.GetUtxo(funding.Txid, 0, false); err != nil || out == nil {
+		t.Fatalf("utxo spent in mempool only should be reported, got %+v, %v", out, err)
+	}
+	if out, err := client.GetUtxo(funding.Txid, 0, true); err != nil || out != nil {
+		t.Fatalf("utxo spent in mempool should be nil, got %+v, %v", out, err)
+	}
+	entries, err := client.GetMemoryPoolVerbose()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := entries[spend.Txid]; !ok || len(entries) != 1 {
+		t.Fatalf("unexpected mempool %v", entries)
+	}
+
+	node.MineBlock(spend)
+	if out, err := client.GetUtxo(funding.Txid, 0, false); err != nil || out != nil {
+		t.Fatalf("spent utxo should be nil, got %+v, %v", out, err)
+	}
+}
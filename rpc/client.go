@@ -1,43 +1,105 @@
 package rpc
 
 import (
-	"bytes"
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"github.com/Qitmeer/qitmeer/params"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Client struct {
-	rpcCfg *RpcConfig
+	rpcCfg    *RpcConfig
+	transport Transport
+	pool      *nodePool
+	limiter   *limiter
+	// err is the error met while setting up the transport,
+	// it is returned by every call
+	err error
 }
 
 type RpcConfig struct {
 	Address string
-	User    string
-	Pwd     string
-	Https   bool
+	// Addresses are further nodes sharing the credentials and tls options.
+	// Reads go to the healthiest node and fail over to the others,
+	// transactions are broadcast to all of them.
+	Addresses []string
+	User      string
+	Pwd       string
+	Https     bool
+	// ConnectTimeout bounds dialing the node, ReadTimeout bounds a whole
+	// request including reading the response. Zero means no limit.
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	// Tls options, used when Https is true.
+	// CAFile is a PEM bundle to verify the node certificate with, the system
+	// roots are used when it is empty. CertFile and KeyFile are an optional
+	// client certificate. InsecureSkipVerify disables verification and should
+	// only be used against a trusted local node.
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+	// Retry is the retry policy of the idempotent calls, nil disables retrying
+	Retry *RetryPolicy
+	// WsPath is the path of the websocket notifications, "/ws" by default
+	WsPath string
+	// WrapTransport, when set, wraps the http transport of the client,
+	// e.g. to record the rpc traffic or to replay it
+	WrapTransport func(Transport) Transport
+	// RateLimit is the number of requests per second sent to the nodes,
+	// RateBurst the number which may be sent at once. MaxInFlight bounds
+	// the requests waiting for a response. Zero means no limit. Requests
+	// wait by the priority of their context, see WithPriority.
+	RateLimit   float64
+	RateBurst   int
+	MaxInFlight int
+	// ChainParams are used to decode raw blocks, see GetRawBlockByOrder
+	ChainParams *params.Params
+	// VerifyNode, when set, is called by CheckNodes with the node info and the
+	// genesis hash of every node. Only the nodes it accepted are read from and
+	// broadcast to, so CheckNodes has to run before the first call.
+	VerifyNode func(info *NodeInfo, genesis string) error
 }
 
 func NewClient(cfg *RpcConfig) *Client {
-	return &Client{
-		cfg,
+	c := &Client{rpcCfg: cfg, pool: newNodePool(cfg), limiter: newLimiter(cfg)}
+	transport, err := newHttpTransport(cfg)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.transport = transport
+	if cfg.WrapTransport != nil {
+		c.transport = cfg.WrapTransport(transport)
+	}
+	return c
+}
+
+// Close releases the idle connections kept to the node
+func (c *Client) Close() {
+	if closer, ok := c.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
 	}
 }
 
 func (c *Client) GetBlockByOrder(order uint64) (*Block, error) {
+	return c.GetBlockByOrderContext(context.Background(), order)
+}
+
+func (c *Client) GetBlockByOrderContext(ctx context.Context, order uint64) (*Block, error) {
 	params := []interface{}{order, true}
-	resp, err := NewReqeust(params).SetMethod("getBlockByOrder").call(c.rpcCfg)
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getBlockByOrder"))
 	if err != nil {
 		return nil, err
 	}
 	blk := new(Block)
 	if resp.Error != nil {
-		return blk, errors.New(resp.Error.Message)
+		return blk, resp.Error
 	}
 	if err := json.Unmarshal(resp.Result, blk); err != nil {
 		return blk, errors.New("failed to parse response json")
@@ -46,8 +108,12 @@ func (c *Client) GetBlockByOrder(order uint64) (*Block, error) {
 }
 
 func (c *Client) GetBlockCount() string {
+	return c.GetBlockCountContext(context.Background())
+}
+
+func (c *Client) GetBlockCountContext(ctx context.Context) string {
 	var params []interface{}
-	resp, err := NewReqeust(params).SetMethod("getBlockCount").call(c.rpcCfg)
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getBlockCount"))
 	if err != nil {
 		return "-1"
 	}
@@ -58,13 +124,18 @@ func (c *Client) GetBlockCount() string {
 }
 
 func (c *Client) SendTransaction(tx string) (string, error) {
+	return c.SendTransactionContext(context.Background(), tx)
+}
+
+func (c *Client) SendTransactionContext(ctx context.Context, tx string) (string, error) {
 	params := []interface{}{strings.Trim(tx, "\n"), false}
-	resp, err := NewReqeust(params).SetMethod("sendRawTransaction").call(c.rpcCfg)
+	ctx = WithPriority(ctx, priorityFrom(ctx, PriorityHigh))
+	resp, err := c.broadcast(ctx, NewReqeust(params).SetMethod("sendRawTransaction"))
 	if err != nil {
 		return "", err
 	}
 	if resp.Error != nil {
-		return resp.Error.Message, errors.New(resp.Error.Message)
+		return resp.Error.Message, resp.Error
 	}
 	txid := ""
 	json.Unmarshal(resp.Result, &txid)
@@ -72,13 +143,17 @@ func (c *Client) SendTransaction(tx string) (string, error) {
 }
 
 func (c *Client) GetTransaction(txId string) (*Transaction, error) {
+	return c.GetTransactionContext(context.Background(), txId)
+}
+
+func (c *Client) GetTransactionContext(ctx context.Context, txId string) (*Transaction, error) {
 	params := []interface{}{txId, true}
-	resp, err := NewReqeust(params).SetMethod("getRawTransaction").call(c.rpcCfg)
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getRawTransaction"))
 	if err != nil {
 		return nil, err
 	}
 	if resp.Error != nil {
-		return nil, errors.New(resp.Error.Message)
+		return nil, resp.Error
 	}
 	var rs *Transaction
 	if err := json.Unmarshal(resp.Result, &rs); err != nil {
@@ -88,13 +163,17 @@ func (c *Client) GetTransaction(txId string) (*Transaction, error) {
 }
 
 func (c *Client) GetMemoryPool() ([]string, error) {
+	return c.GetMemoryPoolContext(context.Background())
+}
+
+func (c *Client) GetMemoryPoolContext(ctx context.Context) ([]string, error) {
 	params := []interface{}{"", false}
-	resp, err := NewReqeust(params).SetMethod("getMempool").call(c.rpcCfg)
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getMempool"))
 	if err != nil {
 		return nil, err
 	}
 	if resp.Error != nil {
-		return nil, errors.New(resp.Error.Message)
+		return nil, resp.Error
 	}
 	var rs []string
 	if err := json.Unmarshal(resp.Result, &rs); err != nil {
@@ -104,14 +183,39 @@ func (c *Client) GetMemoryPool() ([]string, error) {
 }
 
 func (c *Client) GetBlockById(id uint64) (*Block, error) {
+	return c.GetBlockByIdContext(context.Background(), id)
+}
+
+func (c *Client) GetBlockByIdContext(ctx context.Context, id uint64) (*Block, error) {
 	params := []interface{}{id, true}
-	resp, err := NewReqeust(params).SetMethod("getBlockByID").call(c.rpcCfg)
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getBlockByID"))
 	if err != nil {
 		return nil, err
 	}
 	blk := new(Block)
 	if resp.Error != nil {
-		return blk, errors.New(resp.Error.Message)
+		return blk, resp.Error
+	}
+	if err := json.Unmarshal(resp.Result, blk); err != nil {
+		return blk, errors.New("failed to parse response json")
+	}
+	return blk, nil
+}
+
+// GetBlock returns the block with hash, also when the dag moved it to another order
+func (c *Client) GetBlock(hash string) (*Block, error) {
+	return c.GetBlockContext(context.Background(), hash)
+}
+
+func (c *Client) GetBlockContext(ctx context.Context, hash string) (*Block, error) {
+	params := []interface{}{hash, true}
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getBlock"))
+	if err != nil {
+		return nil, err
+	}
+	blk := new(Block)
+	if resp.Error != nil {
+		return blk, resp.Error
 	}
 	if err := json.Unmarshal(resp.Result, blk); err != nil {
 		return blk, errors.New("failed to parse response json")
@@ -120,14 +224,18 @@ func (c *Client) GetBlockById(id uint64) (*Block, error) {
 }
 
 func (c *Client) GetNodeInfo() (*NodeInfo, error) {
+	return c.GetNodeInfoContext(context.Background())
+}
+
+func (c *Client) GetNodeInfoContext(ctx context.Context) (*NodeInfo, error) {
 	params := []interface{}{}
-	resp, err := NewReqeust(params).SetMethod("getNodeInfo").call(c.rpcCfg)
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("getNodeInfo"))
 	if err != nil {
 		return nil, err
 	}
 	nodeInfo := new(NodeInfo)
 	if resp.Error != nil {
-		return nodeInfo, errors.New(resp.Error.Message)
+		return nodeInfo, resp.Error
 	}
 	if err := json.Unmarshal(resp.Result, nodeInfo); err != nil {
 		return nodeInfo, errors.New("failed to parse response json")
@@ -136,13 +244,17 @@ func (c *Client) GetNodeInfo() (*NodeInfo, error) {
 }
 
 func (c *Client) IsBlue(hash string) (int, error) {
+	return c.IsBlueContext(context.Background(), hash)
+}
+
+func (c *Client) IsBlueContext(ctx context.Context, hash string) (int, error) {
 	params := []interface{}{hash}
-	resp, err := NewReqeust(params).SetMethod("isBlue").call(c.rpcCfg)
+	resp, err := c.call(ctx, NewReqeust(params).SetMethod("isBlue"))
 	if err != nil {
 		return 0, err
 	}
 	if resp.Error != nil {
-		return 0, errors.New(resp.Error.Message)
+		return 0, resp.Error
 	}
 	state, err := strconv.Atoi(string(resp.Result))
 	if err != nil {
@@ -151,58 +263,101 @@ func (c *Client) IsBlue(hash string) (int, error) {
 	return state, nil
 }
 
-func (req *ClientRequest) call(rpcCfg *RpcConfig) (*ClientResponse, error) {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-
-	client := &http.Client{Transport: tr}
-	defer client.CloseIdleConnections()
-
-	//convert struct to []byte
-	marshaledData, err := json.Marshal(req)
+func (c *Client) call(ctx context.Context, req *ClientRequest) (*ClientResponse, error) {
+	bodyBytes, err := c.postRetry(ctx, req, req.Method)
 	if err != nil {
-		return nil, fmt.Errorf("rpc client encoding json failed; error:%s ", err.Error())
+		return nil, err
 	}
-	httpUrl := "http://"
-	if rpcCfg.Https {
-		httpUrl = "https://"
+	return decodeResponse(req, bodyBytes)
+}
+
+// postRetry posts the payload and retries it according to the retry policy
+// as long as the nodes can not be reached
+func (c *Client) postRetry(ctx context.Context, payload interface{}, methods ...string) ([]byte, error) {
+	policy := c.rpcCfg.Retry
+	for attempt := 1; ; attempt++ {
+		bodyBytes, err := c.postAny(ctx, payload)
+		if _, ok := err.(*TransportError); !ok || policy == nil || attempt >= policy.MaxAttempts {
+			return bodyBytes, err
+		}
+		for _, method := range methods {
+			if !policy.CanRetry(method) {
+				return bodyBytes, err
+			}
+		}
+		if e := policy.Wait(ctx, attempt); e != nil {
+			return nil, e
+		}
 	}
+}
 
-	httpRequest, err :=
-		http.NewRequest(http.MethodPost, httpUrl+rpcCfg.Address, bytes.NewReader(marshaledData))
-	if err != nil {
-		return nil, fmt.Errorf("rpc client create request failed; error:%s ", err.Error())
+func decodeResponse(req *ClientRequest, bodyBytes []byte) (*ClientResponse, error) {
+	resp := &ClientResponse{}
+	//convert []byte to struct
+	if err := json.Unmarshal(bodyBytes, resp); err != nil {
+		return nil, fmt.Errorf("json unmarshal failed; value:%s; error:%s", string(bodyBytes), err.Error())
 	}
-	if httpRequest == nil {
-		return nil, fmt.Errorf("rpc client create request failed")
+	if resp.Error != nil {
+		resp.Error.Method = req.Method
 	}
-	httpRequest.Close = true
-	httpRequest.Header.Set("Content-Type", "application/json")
-	httpRequest.SetBasicAuth(rpcCfg.User, rpcCfg.Pwd)
+	return resp, nil
+}
 
-	response, err := client.Do(httpRequest)
+// callNode sends req to the node at addr only
+func (c *Client) callNode(ctx context.Context, addr string, req *ClientRequest) (*ClientResponse, error) {
+	bodyBytes, err := c.post(ctx, addr, req)
 	if err != nil {
-		return &ClientResponse{Error: &Error{Message: err.Error()}}, nil
+		return nil, err
 	}
+	return decodeResponse(req, bodyBytes)
+}
 
-	body := response.Body
-
-	bodyBytes, err := ioutil.ReadAll(body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body; error:%s", err.Error())
-	}
+// postAny posts the payload to the current node and fails over to
+// the other nodes of the pool while they can not be reached
+func (c *Client) postAny(ctx context.Context, payload interface{}) ([]byte, error) {
+	addrs := c.pool.candidates()
+	if len(addrs) == 0 {
+		return nil, errors.New("no verified rpc node")
+	}
+	var err error
+	for _, addr := range addrs {
+		var bodyBytes []byte
+		bodyBytes, err = c.post(ctx, addr, payload)
+		if _, ok := err.(*TransportError); ok {
+			continue
+		}
+		return bodyBytes, err
+	}
+	return nil, err
+}
 
-	resp := &ClientResponse{}
-	//convert []byte to struct
-	if err := json.Unmarshal(bodyBytes, resp); err != nil {
-		return nil, fmt.Errorf("json unmarshal failed; value:%s; error:%s", string(bodyBytes), err.Error())
+// post sends the json encoded payload to the node at addr and returns the response body.
+// Failing to reach the node is reported as a *TransportError and marks the node down.
+func (c *Client) post(ctx context.Context, addr string, payload interface{}) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
 	}
 
-	err = response.Body.Close()
+	//convert struct to []byte
+	marshaledData, err := json.Marshal(payload)
 	if err != nil {
-		return nil, fmt.Errorf("close response failed; error:%s", err.Error())
+		return nil, fmt.Errorf("rpc client encoding json failed; error:%s ", err.Error())
+	}
+	if c.limiter != nil {
+		if err := c.limiter.acquire(ctx, priorityFrom(ctx, PriorityNormal)); err != nil {
+			return nil, err
+		}
+		defer c.limiter.release()
 	}
 
-	return resp, nil
+	bodyBytes, err := c.transport.RoundTrip(ctx, addr, marshaledData)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		c.pool.markDown(addr, err)
+		return nil, &TransportError{Address: addr, Err: err}
+	}
+	c.pool.markUp(addr)
+	return bodyBytes, nil
 }
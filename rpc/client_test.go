@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewClient(&RpcConfig{Address: strings.TrimPrefix(srv.URL, "http://")})
+}
+
+func TestClient_ContextCanceled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetNodeInfoContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("request was not canceled")
+	}
+}
+
+func TestClient_GetNodeInfo(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"confirmations":10,"coinbasematurity":720}}`))
+	})
+	info, err := client.GetNodeInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Confirmations != 10 || info.Coinbasematurity != 720 {
+		t.Fatalf("unexpected node info %+v", info)
+	}
+}
+
+func TestClient_VerifyTls(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"confirmations":10}}`))
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "https://")
+
+	client := NewClient(&RpcConfig{Address: addr, Https: true})
+	if _, err := client.GetNodeInfo(); err == nil {
+		t.Fatalf("expected unknown certificate to be rejected")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client = NewClient(&RpcConfig{Address: addr, Https: true, CAFile: caFile})
+	defer client.Close()
+	if _, err := client.GetNodeInfo(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClient_Failover(t *testing.T) {
+	var sent [2]int
+	handler := func(i int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req ClientRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Method == "sendRawTransaction" {
+				sent[i]++
+				w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"txid"}`))
+				return
+			}
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"confirmations":10}}`))
+		}
+	}
+	down := httptest.NewServer(handler(0))
+	up := httptest.NewServer(handler(1))
+	defer up.Close()
+	downAddr := strings.TrimPrefix(down.URL, "http://")
+	upAddr := strings.TrimPrefix(up.URL, "http://")
+
+	client := NewClient(&RpcConfig{Address: downAddr, Addresses: []string{upAddr}})
+	if _, err := client.SendTransaction("00"); err != nil {
+		t.Fatal(err)
+	}
+	if sent[0] != 1 || sent[1] != 1 {
+		t.Fatalf("transaction was not broadcast to every node %v", sent)
+	}
+
+	down.Close()
+	if _, err := client.GetNodeInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if client.CurrentNode() != upAddr {
+		t.Fatalf("expected reads to fail over to %s, current %s", upAddr, client.CurrentNode())
+	}
+}
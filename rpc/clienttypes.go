@@ -18,6 +18,8 @@ type Error struct {
 	Code    int         `json:"code,omitempty"`
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
+	// Method is the rpc method that failed
+	Method string `json:"-"`
 }
 
 func NewReqeust(params []interface{}) *ClientRequest {
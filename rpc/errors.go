@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"errors"
+	"strings"
+)
+
+// Errors the node reports about transactions, match them with errors.Is
+var (
+	ErrTxNotFound       = errors.New("transaction not found")
+	ErrAlreadyInMempool = errors.New("transaction already in mempool")
+	ErrDoubleSpend      = errors.New("transaction spends coins already spent in mempool")
+	ErrInsufficientFee  = errors.New("transaction fee is insufficient")
+	ErrTxTooBig         = errors.New("transaction is too big")
+)
+
+// json-rpc error codes of the node
+const (
+	// ErrCodeInvalidAddressOrKey is returned for unknown transactions and blocks
+	ErrCodeInvalidAddressOrKey = -5
+)
+
+// TransportError means the node could not be reached or the response could not be read
+type TransportError struct {
+	Address string
+	Err     error
+}
+
+func (e *TransportError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// Error is an error returned by the node, it keeps the json-rpc code
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrTxNotFound:
+		if e.Code == ErrCodeInvalidAddressOrKey && e.Method == "getRawTransaction" {
+			return true
+		}
+		return strings.Contains(e.Message, "No information available about transaction")
+	case ErrAlreadyInMempool:
+		return strings.Contains(e.Message, "already have transaction")
+	case ErrDoubleSpend:
+		return strings.Contains(e.Message, "in the pool already spends the same coins")
+	case ErrInsufficientFee:
+		return strings.Contains(e.Message, "fees which is under the required amount of")
+	case ErrTxTooBig:
+		return strings.Contains(e.Message, "is larger than max allowed size of")
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClient_TypedErrors(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-5,"message":"No information available about transaction 0000"}}`))
+	})
+	_, err := client.GetTransaction("0000")
+	if !errors.Is(err, ErrTxNotFound) {
+		t.Fatalf("expected ErrTxNotFound, got %v", err)
+	}
+	var nodeErr *Error
+	if !errors.As(err, &nodeErr) || nodeErr.Code != -5 || nodeErr.Method != "getRawTransaction" {
+		t.Fatalf("expected node error with code, got %#v", err)
+	}
+
+	client = newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-40,"message":"already have transaction 0000"}}`))
+	})
+	if _, err := client.SendTransaction("00"); !errors.Is(err, ErrAlreadyInMempool) {
+		t.Fatalf("expected ErrAlreadyInMempool, got %v", err)
+	}
+
+	client = NewClient(&RpcConfig{Address: "127.0.0.1:1"})
+	_, err = client.GetNodeInfo()
+	var transportErr *TransportError
+	if !errors.As(err, &transportErr) {
+		t.Fatalf("expected transport error, got %#v", err)
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	client := NewClient(&RpcConfig{
+		Address: "127.0.0.1:1",
+		Retry:   &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond},
+	})
+	attempts := 0
+	client.rpcCfg.Retry.Retryable = func(method string) bool {
+		if !idempotentMethods[method] {
+			return false
+		}
+		attempts++
+		return true
+	}
+	if _, err := client.GetNodeInfo(); err == nil {
+		t.Fatalf("expected error")
+	}
+	if attempts != 2 {
+		t.Fatalf("expected 2 retries, got %d", attempts)
+	}
+
+	attempts = 0
+	if _, err := client.SendTransaction("00"); err == nil {
+		t.Fatalf("expected error")
+	}
+	if attempts != 0 {
+		t.Fatalf("transactions must not be retried")
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: time.Millisecond, Multiplier: 2}
+	for _, attempt := range []int{1, 30, 100, 5000} {
+		if backoff := policy.Backoff(attempt); backoff <= 0 || backoff > defaultRetryMaxBackoff {
+			t.Fatalf("backoff of attempt %d is %s", attempt, backoff)
+		}
+	}
+	policy.MaxBackoff = 50 * time.Millisecond
+	if backoff := policy.Backoff(5000); backoff != policy.MaxBackoff {
+		t.Fatalf("expected the backoff to be capped at %s, got %s", policy.MaxBackoff, backoff)
+	}
+}
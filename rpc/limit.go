@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Priority orders the requests waiting for the rate and in flight limits
+type Priority int
+
+const (
+	// PriorityBackground is for reconciliation and other work which can wait
+	PriorityBackground Priority = iota
+	// PriorityNormal is the priority of a request without one, e.g. sync
+	PriorityNormal
+	// PriorityHigh is the default priority of broadcasting transactions
+	PriorityHigh
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose requests are limited with priority p
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFrom(ctx context.Context, def Priority) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return def
+}
+
+// limiter bounds the requests sent to the nodes with a token bucket and
+// a maximum number of requests in flight. Waiting requests are let through
+// by priority, in arrival order within the same priority.
+type limiter struct {
+	rate        float64
+	burst       float64
+	maxInFlight int
+
+	mutex    sync.Mutex
+	tokens   float64
+	last     time.Time
+	inFlight int
+	waiters  [PriorityHigh + 1][]chan struct{}
+	timer    *time.Timer
+}
+
+// newLimiter returns nil when the config sets no limit
+func newLimiter(cfg *RpcConfig) *limiter {
+	if cfg.RateLimit <= 0 && cfg.MaxInFlight <= 0 {
+		return nil
+	}
+	burst := float64(cfg.RateBurst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		rate:        cfg.RateLimit,
+		burst:       burst,
+		maxInFlight: cfg.MaxInFlight,
+		tokens:      burst,
+		last:        time.Now(),
+	}
+}
+
+// acquire waits until a request of priority p may be sent,
+// release must be called once it is done
+func (l *limiter) acquire(ctx context.Context, p Priority) error {
+	if p < PriorityBackground {
+		p = PriorityBackground
+	} else if p > PriorityHigh {
+		p = PriorityHigh
+	}
+	ready := make(chan struct{})
+	l.mutex.Lock()
+	l.waiters[p] = append(l.waiters[p], ready)
+	l.dispatch()
+	l.mutex.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if !l.remove(p, ready) {
+			// let through meanwhile, give the slot back
+			l.inFlight--
+			l.dispatch()
+		}
+		return ctx.Err()
+	}
+}
+
+func (l *limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inFlight--
+	l.dispatch()
+}
+
+// dispatch lets the waiting requests through while the limits allow it,
+// it is called with the mutex held
+func (l *limiter) dispatch() {
+	for p := PriorityHigh; p >= PriorityBackground; p-- {
+		for len(l.waiters[p]) != 0 {
+			if l.maxInFlight > 0 && l.inFlight >= l.maxInFlight {
+				return
+			}
+			if !l.take() {
+				return
+			}
+			close(l.waiters[p][0])
+			l.waiters[p] = l.waiters[p][1:]
+			l.inFlight++
+		}
+	}
+}
+
+// take takes a token from the bucket, when it is empty
+// dispatch is scheduled for the time the next token is added
+func (l *limiter) take() bool {
+	if l.rate <= 0 {
+		return true
+	}
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return true
+	}
+	if l.timer == nil {
+		wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		l.timer = time.AfterFunc(wait, func() {
+			l.mutex.Lock()
+			defer l.mutex.Unlock()
+			l.timer = nil
+			l.dispatch()
+		})
+	}
+	return false
+}
+
+func (l *limiter) remove(p Priority, ready chan struct{}) bool {
+	for i, ch := range l.waiters[p] {
+		if ch == ready {
+			l.waiters[p] = append(l.waiters[p][:i], l.waiters[p][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
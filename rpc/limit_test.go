@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Priority(t *testing.T) {
+	l := newLimiter(&RpcConfig{MaxInFlight: 1})
+	if err := l.acquire(context.Background(), PriorityNormal); err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan Priority, 2)
+	start := func(p Priority) {
+		go func() {
+			if err := l.acquire(context.Background(), p); err != nil {
+				t.Error(err)
+				return
+			}
+			order <- p
+			l.release()
+		}()
+	}
+	start(PriorityBackground)
+	time.Sleep(10 * time.Millisecond)
+	start(PriorityHigh)
+	time.Sleep(10 * time.Millisecond)
+
+	// a canceled request gives up its place
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(ctx, PriorityHigh); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	l.release()
+	if p := <-order; p != PriorityHigh {
+		t.Fatalf("expected the high priority request first, got %d", p)
+	}
+	if p := <-order; p != PriorityBackground {
+		t.Fatalf("expected the background request second, got %d", p)
+	}
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	l := newLimiter(&RpcConfig{RateLimit: 100, RateBurst: 2})
+	begin := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := l.acquire(context.Background(), PriorityNormal); err != nil {
+			t.Fatal(err)
+		}
+		l.release()
+	}
+	// the burst goes at once, the other 4 requests wait 10ms each
+	if elapsed := time.Since(begin); elapsed < 35*time.Millisecond {
+		t.Fatalf("6 requests took %s", elapsed)
+	}
+	if newLimiter(&RpcConfig{}) != nil {
+		t.Fatal("expected no limiter without limits")
+	}
+}
@@ -0,0 +1,205 @@
+package rpc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/bCoder778/log"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWsPath       = "/ws"
+	notifyChannelLength = 100
+	// the socket is pinged this often and dropped when nothing
+	// was read for wsReadTimeout, a half-open connection is noticed
+	wsPingInterval = 30 * time.Second
+	wsReadTimeout  = 2 * wsPingInterval
+)
+
+// BlockConnected is sent by the node when a block is added to the dag
+type BlockConnected struct {
+	Hash   string
+	Height uint64
+	Order  uint64
+}
+
+// TxAccepted is sent by the node when a transaction enters the mempool
+type TxAccepted struct {
+	Txid   string
+	Amount json.RawMessage
+}
+
+// NotifyClient subscribes to the block and transaction notifications of the node
+// over a websocket and reconnects whenever the socket goes down
+type NotifyClient struct {
+	cfg       *RpcConfig
+	blocks    chan *BlockConnected
+	txs       chan *TxAccepted
+	connected int32
+
+	pingInterval time.Duration
+	readTimeout  time.Duration
+}
+
+func NewNotifyClient(cfg *RpcConfig) *NotifyClient {
+	return &NotifyClient{
+		cfg:          cfg,
+		blocks:       make(chan *BlockConnected, notifyChannelLength),
+		txs:          make(chan *TxAccepted, notifyChannelLength),
+		pingInterval: wsPingInterval,
+		readTimeout:  wsReadTimeout,
+	}
+}
+
+// Blocks returns the block connected notifications, they are dropped when nobody reads them
+func (n *NotifyClient) Blocks() <-chan *BlockConnected {
+	return n.blocks
+}
+
+// Txs returns the tx accepted notifications, they are dropped when nobody reads them
+func (n *NotifyClient) Txs() <-chan *TxAccepted {
+	return n.txs
+}
+
+// Connected reports whether the notification socket is up
+func (n *NotifyClient) Connected() bool {
+	return atomic.LoadInt32(&n.connected) == 1
+}
+
+// Run keeps the socket connected until ctx is done
+func (n *NotifyClient) Run(ctx context.Context) {
+	policy := n.cfg.Retry
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err := n.serve(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Debugf("notification socket is down, polling until it reconnects, %s", err.Error())
+		// a connection which lived for a while starts the backoff again
+		if time.Since(start) > policy.MaxBackoff {
+			attempt = 1
+		}
+		if policy.Wait(ctx, attempt) != nil {
+			return
+		}
+	}
+}
+
+// serve subscribes to the notifications over one socket until it fails or ctx is done
+func (n *NotifyClient) serve(ctx context.Context) error {
+	// the socket is closed when serve returns
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tlsCfg, err := newTlsConfig(n.cfg)
+	if err != nil {
+		return err
+	}
+	path := n.cfg.WsPath
+	if path == "" {
+		path = defaultWsPath
+	}
+	addr := n.cfg.Address
+	if addr == "" && len(n.cfg.Addresses) != 0 {
+		addr = n.cfg.Addresses[0]
+	}
+	u, err := wsUrl(addr, n.cfg.Https, path)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	auth := base64.StdEncoding.EncodeToString([]byte(n.cfg.User + ":" + n.cfg.Pwd))
+	header.Set("Authorization", "Basic "+auth)
+	ws, err := dialWebsocket(ctx, u, header, n.cfg.ConnectTimeout, tlsCfg)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	// every pong and message gives the node another readTimeout
+	ws.SetReadDeadline(time.Now().Add(n.readTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(n.readTimeout))
+	})
+	go func() {
+		ping := time.NewTicker(n.pingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ping.C:
+				if ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(n.pingInterval)) != nil {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	for _, req := range []*ClientRequest{
+		NewReqeust([]interface{}{}).SetMethod("notifyBlocks"),
+		NewReqeust([]interface{}{false}).SetMethod("notifyNewTransactions"),
+	} {
+		if err := ws.WriteJSON(req); err != nil {
+			return err
+		}
+	}
+
+	atomic.StoreInt32(&n.connected, 1)
+	defer atomic.StoreInt32(&n.connected, 0)
+	for {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			return err
+		}
+		ws.SetReadDeadline(time.Now().Add(n.readTimeout))
+		n.dispatch(msg)
+	}
+}
+
+type notification struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func (n *NotifyClient) dispatch(msg []byte) {
+	ntfn := &notification{}
+	if err := json.Unmarshal(msg, ntfn); err != nil || ntfn.Method == "" || len(ntfn.Params) == 0 {
+		// replies to the subscriptions
+		return
+	}
+	switch ntfn.Method {
+	case "blockConnected":
+		blk := &BlockConnected{}
+		json.Unmarshal(ntfn.Params[0], &blk.Hash)
+		if len(ntfn.Params) > 1 {
+			json.Unmarshal(ntfn.Params[1], &blk.Height)
+		}
+		if len(ntfn.Params) > 2 {
+			json.Unmarshal(ntfn.Params[2], &blk.Order)
+		}
+		select {
+		case n.blocks <- blk:
+		default:
+		}
+	case "txaccepted":
+		tx := &TxAccepted{}
+		json.Unmarshal(ntfn.Params[0], &tx.Txid)
+		if len(ntfn.Params) > 1 {
+			tx.Amount = ntfn.Params[1]
+		}
+		select {
+		case n.txs <- tx:
+		default:
+		}
+	}
+}
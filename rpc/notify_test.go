@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyClient_Blocks(t *testing.T) {
+	srv := newNotifyServer(t, func(ws *websocket.Conn) {
+		ws.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"blockConnected","params":["hash",12,34]}`))
+		ws.ReadMessage()
+	})
+	defer srv.Close()
+
+	client := NewNotifyClient(&RpcConfig{
+		Address: strings.TrimPrefix(srv.URL, "http://"),
+		User:    "admin",
+		Pwd:     "123",
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	select {
+	case blk := <-client.Blocks():
+		if blk.Hash != "hash" || blk.Height != 12 || blk.Order != 34 {
+			t.Fatalf("unexpected notification %+v", blk)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no block notification")
+	}
+	if !client.Connected() {
+		t.Fatalf("expected client to be connected")
+	}
+}
+
+func TestNotifyClient_HalfOpen(t *testing.T) {
+	silent := make(chan struct{})
+	srv := newNotifyServer(t, func(ws *websocket.Conn) {
+		// neither answers the pings nor closes the socket
+		<-silent
+	})
+	defer srv.Close()
+	defer close(silent)
+
+	client := NewNotifyClient(&RpcConfig{
+		Address: strings.TrimPrefix(srv.URL, "http://"),
+		Retry:   &RetryPolicy{MaxAttempts: 1, InitialBackoff: time.Hour, MaxBackoff: time.Hour},
+	})
+	client.pingInterval = 20 * time.Millisecond
+	client.readTimeout = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	waitConnected(t, client, true)
+	waitConnected(t, client, false)
+}
+
+func waitConnected(t *testing.T, client *NotifyClient, connected bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for client.Connected() != connected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected connected %v", connected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newNotifyServer accepts websockets, checks the subscriptions and hands the socket to serve
+func newNotifyServer(t *testing.T, serve func(ws *websocket.Conn)) *httptest.Server {
+	upgrader := &websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws" || r.Header.Get("Authorization") == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer ws.Close()
+
+		for _, method := range []string{"notifyBlocks", "notifyNewTransactions"} {
+			req := &ClientRequest{}
+			if err := ws.ReadJSON(req); err != nil || req.Method != method {
+				t.Errorf("expected %s subscription, got %+v, %v", method, req, err)
+				return
+			}
+		}
+		serve(ws)
+	}))
+}
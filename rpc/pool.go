@@ -0,0 +1,335 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxNodeLag is how many orders the current node may fall behind
+// the best node before reads are switched over
+const maxNodeLag = 2
+
+// NodeStatus is the last known state of one node of the pool
+type NodeStatus struct {
+	Address    string        `json:"address"`
+	Healthy    bool          `json:"healthy"`
+	Current    bool          `json:"current"`
+	MainOrder  uint64        `json:"mainorder"`
+	BlockCount uint64        `json:"blockcount"`
+	Latency    time.Duration `json:"latency"`
+	LastError  string        `json:"lasterror"`
+	LastCheck  time.Time     `json:"lastcheck"`
+	// Verified is set once the node was accepted by RpcConfig.VerifyNode
+	Verified bool `json:"verified"`
+}
+
+type nodePool struct {
+	mutex   sync.RWMutex
+	nodes   []*NodeStatus
+	current int
+	// only the verified nodes are used, see RpcConfig.VerifyNode
+	verify bool
+}
+
+func newNodePool(cfg *RpcConfig) *nodePool {
+	pool := &nodePool{verify: cfg.VerifyNode != nil}
+	seen := map[string]bool{}
+	for _, addr := range append([]string{cfg.Address}, cfg.Addresses...) {
+		if addr == "" || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		pool.nodes = append(pool.nodes, &NodeStatus{Address: addr, Healthy: true})
+	}
+	return pool
+}
+
+func (p *nodePool) addresses() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	addrs := make([]string, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		addrs = append(addrs, node.Address)
+	}
+	return addrs
+}
+
+// usable returns the addresses of the nodes which may be used
+func (p *nodePool) usable() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	addrs := make([]string, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		if p.usableNode(node) {
+			addrs = append(addrs, node.Address)
+		}
+	}
+	return addrs
+}
+
+func (p *nodePool) usableNode(node *NodeStatus) bool {
+	return !p.verify || node.Verified
+}
+
+func (p *nodePool) verified(addr string) bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for _, node := range p.nodes {
+		if node.Address == addr {
+			return node.Verified
+		}
+	}
+	return false
+}
+
+// candidates returns the current node first, then the other healthy
+// nodes and finally the unhealthy ones as a last resort
+func (p *nodePool) candidates() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if len(p.nodes) == 0 {
+		return nil
+	}
+	var addrs, down []string
+	if cur := p.nodes[p.current]; p.usableNode(cur) {
+		addrs = append(addrs, cur.Address)
+	}
+	for i, node := range p.nodes {
+		if i == p.current || !p.usableNode(node) {
+			continue
+		}
+		if node.Healthy {
+			addrs = append(addrs, node.Address)
+		} else {
+			down = append(down, node.Address)
+		}
+	}
+	return append(addrs, down...)
+}
+
+func (p *nodePool) markDown(addr string, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, node := range p.nodes {
+		if node.Address == addr {
+			node.Healthy = false
+			node.LastError = err.Error()
+		}
+	}
+	p.selectBest()
+}
+
+func (p *nodePool) markUp(addr string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, node := range p.nodes {
+		if node.Address == addr && !node.Healthy {
+			node.Healthy = true
+			node.LastError = ""
+			p.selectBest()
+		}
+	}
+}
+
+func (p *nodePool) update(status *NodeStatus) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, node := range p.nodes {
+		if node.Address == status.Address {
+			p.nodes[i] = status
+		}
+	}
+}
+
+// selectBest keeps the current node while it is healthy and not lagging,
+// otherwise it switches to the healthy node with the highest main order
+func (p *nodePool) selectBest() {
+	best := -1
+	for i, node := range p.nodes {
+		if !node.Healthy || !p.usableNode(node) {
+			continue
+		}
+		if best == -1 || node.MainOrder > p.nodes[best].MainOrder ||
+			(node.MainOrder == p.nodes[best].MainOrder && node.Latency < p.nodes[best].Latency) {
+			best = i
+		}
+	}
+	if best == -1 {
+		return
+	}
+	cur := p.nodes[p.current]
+	if cur.Healthy && p.usableNode(cur) && cur.MainOrder+maxNodeLag >= p.nodes[best].MainOrder {
+		return
+	}
+	p.current = best
+}
+
+func (p *nodePool) status() []NodeStatus {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	nodes := make([]NodeStatus, 0, len(p.nodes))
+	for i, node := range p.nodes {
+		n := *node
+		n.Current = i == p.current
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// CurrentNode returns the address of the node reads are sent to
+func (c *Client) CurrentNode() string {
+	c.pool.mutex.RLock()
+	defer c.pool.mutex.RUnlock()
+
+	if len(c.pool.nodes) == 0 {
+		return ""
+	}
+	return c.pool.nodes[c.pool.current].Address
+}
+
+// Nodes returns the last known state of every node
+func (c *Client) Nodes() []NodeStatus {
+	return c.pool.status()
+}
+
+// CheckNodes probes every node with getNodeInfo and getBlockCount, verifies
+// it with RpcConfig.VerifyNode and routes reads to the healthiest one
+func (c *Client) CheckNodes(ctx context.Context) {
+	addrs := c.pool.addresses()
+	wg := sync.WaitGroup{}
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			c.pool.update(c.probe(ctx, addr))
+		}(addr)
+	}
+	wg.Wait()
+
+	c.pool.mutex.Lock()
+	c.pool.selectBest()
+	c.pool.mutex.Unlock()
+}
+
+// RunHealthCheck checks the nodes every interval until ctx is done
+func (c *Client) RunHealthCheck(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		c.CheckNodes(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) probe(ctx context.Context, addr string) *NodeStatus {
+	// a node which can not be reached keeps its verification
+	status := &NodeStatus{Address: addr, LastCheck: time.Now(), Verified: c.pool.verified(addr)}
+	start := time.Now()
+	resp, err := c.callNode(ctx, addr, NewReqeust([]interface{}{}).SetMethod("getNodeInfo"))
+	if err == nil && resp.Error != nil {
+		err = resp.Error
+	}
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+	status.Latency = time.Since(start)
+	nodeInfo := new(NodeInfo)
+	if err := json.Unmarshal(resp.Result, nodeInfo); err != nil {
+		status.LastError = "failed to parse response json"
+		return status
+	}
+	status.MainOrder = nodeInfo.Mainorder
+	if c.rpcCfg.VerifyNode != nil {
+		resp, err = c.callNode(ctx, addr, NewReqeust([]interface{}{0}).SetMethod("getBlockhash"))
+		if err == nil && resp.Error != nil {
+			err = resp.Error
+		}
+		if err != nil {
+			status.LastError = err.Error()
+			return status
+		}
+		var genesis string
+		if err := json.Unmarshal(resp.Result, &genesis); err != nil {
+			status.LastError = "failed to parse response json"
+			return status
+		}
+		if err := c.rpcCfg.VerifyNode(nodeInfo, genesis); err != nil {
+			status.LastError = err.Error()
+			status.Verified = false
+			return status
+		}
+		status.Verified = true
+	}
+
+	resp, err = c.callNode(ctx, addr, NewReqeust([]interface{}{}).SetMethod("getBlockCount"))
+	if err == nil && resp.Error != nil {
+		err = resp.Error
+	}
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+	count, err := strconv.ParseUint(strings.TrimSpace(string(resp.Result)), 10, 64)
+	if err != nil {
+		status.LastError = err.Error()
+		return status
+	}
+	status.BlockCount = count
+	status.Healthy = true
+	return status
+}
+
+// broadcast sends req to every node of the pool. The first successful response wins,
+// otherwise a node error is preferred over a node that could not be reached.
+func (c *Client) broadcast(ctx context.Context, req *ClientRequest) (*ClientResponse, error) {
+	addrs := c.pool.usable()
+	if len(addrs) <= 1 {
+		return c.call(ctx, req)
+	}
+
+	type result struct {
+		resp *ClientResponse
+		err  error
+	}
+	results := make([]result, len(addrs))
+	wg := sync.WaitGroup{}
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			resp, err := c.callNode(ctx, addr, req)
+			results[i] = result{resp, err}
+		}(i, addr)
+	}
+	wg.Wait()
+
+	var failed *result
+	for i := range results {
+		rs := &results[i]
+		if rs.err == nil && rs.resp.Error == nil {
+			return rs.resp, nil
+		}
+		if failed == nil || (failed.err != nil && rs.err == nil) {
+			failed = rs
+		}
+	}
+	return failed.resp, failed.err
+}
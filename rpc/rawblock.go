@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+)
+
+// NetParams returns the chain parameters of a network
+// by the name used by qitmeer, e.g. "mainnet" or "testnet"
+func NetParams(network string) (*params.Params, error) {
+	switch network {
+	case "mainnet":
+		return &params.MainNetParams, nil
+	case "testnet":
+		return &params.TestNetParams, nil
+	case "mixnet":
+		return &params.MixNetParams, nil
+	case "privnet":
+		return &params.PrivNetParams, nil
+	}
+	return nil, fmt.Errorf("unknown network %s", network)
+}
+
+// GetRawBlockByOrder fetches the block at order as serialized bytes together
+// with its verbose header, and decodes the transactions locally. It returns
+// the same block as GetBlockByOrder without the hex copies of the transactions,
+// except that Duplicate is never set: a transaction included by several blocks
+// of the dag is returned by each of them. RpcConfig.ChainParams must be set.
+func (c *Client) GetRawBlockByOrder(order uint64) (*Block, error) {
+	return c.GetRawBlockByOrderContext(context.Background(), order)
+}
+
+func (c *Client) GetRawBlockByOrderContext(ctx context.Context, order uint64) (*Block, error) {
+	if c.rpcCfg.ChainParams == nil {
+		return nil, errors.New("chain params are required to decode raw blocks")
+	}
+	resps, err := c.BatchCall(ctx, []*ClientRequest{
+		// verbose without the transactions
+		NewReqeust([]interface{}{order, true, false}).SetMethod("getBlockByOrder"),
+		NewReqeust([]interface{}{order, false}).SetMethod("getBlockByOrder"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range resps {
+		if resp.Error != nil {
+			return nil, resp.Error
+		}
+	}
+	blk := new(Block)
+	if err := json.Unmarshal(resps[0].Result, blk); err != nil {
+		return nil, errors.New("failed to parse response json")
+	}
+	var raw string
+	if err := json.Unmarshal(resps[1].Result, &raw); err != nil {
+		return nil, errors.New("failed to parse response json")
+	}
+	decoded, err := DecodeBlock(raw, c.rpcCfg.ChainParams)
+	if err != nil {
+		return nil, err
+	}
+	if decoded.Hash != blk.Hash {
+		return nil, fmt.Errorf("block of order %d changed from %s to %s", order, blk.Hash, decoded.Hash)
+	}
+	blk.Transactions = decoded.Transactions
+	for i := range blk.Transactions {
+		blk.Transactions[i].Confirmations = blk.Confirmations
+	}
+	return blk, nil
+}
+
+// DecodeBlock decodes a serialized block, the fields which are
+// not part of the serialization like Order are left empty
+func DecodeBlock(raw string, net *params.Params) (*Block, error) {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw block; error:%s", err.Error())
+	}
+	var block types.Block
+	if err := block.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to deserialize block; error:%s", err.Error())
+	}
+	header := &block.Header
+	blk := &Block{
+		Hash:         block.BlockHash().String(),
+		Version:      header.Version,
+		TxRoot:       header.TxRoot.String(),
+		StateRoot:    header.StateRoot.String(),
+		Difficulty:   uint64(header.Difficulty),
+		Timestamp:    header.Timestamp,
+		Transactions: make([]Transaction, 0, len(block.Transactions)),
+	}
+	for _, parent := range block.Parents {
+		blk.ParentHash = append(blk.ParentHash, parent.String())
+	}
+	for _, tx := range block.Transactions {
+		rs, err := decodeTransaction(tx, net)
+		if err != nil {
+			return nil, err
+		}
+		rs.Blockhash = blk.Hash
+		blk.Transactions = append(blk.Transactions, *rs)
+	}
+	return blk, nil
+}
+
+func decodeTransaction(tx *types.Transaction, net *params.Params) (*Transaction, error) {
+	rs := &Transaction{
+		Txid:      tx.TxHash().String(),
+		Txhash:    tx.TxHashFull().String(),
+		Version:   tx.Version,
+		Locktime:  tx.LockTime,
+		Timestamp: tx.Timestamp,
+		Expire:    tx.Expire,
+		Vin:       make([]Vin, 0, len(tx.TxIn)),
+		Vout:      make([]Vout, 0, len(tx.TxOut)),
+	}
+	coinBase := tx.IsCoinBase()
+	for _, in := range tx.TxIn {
+		vin := Vin{Sequence: uint64(in.Sequence)}
+		if coinBase {
+			vin.Coinbase = hex.EncodeToString(in.SignScript)
+		} else {
+			vin.Txid = in.PreviousOut.Hash.String()
+			vin.Vout = uint64(in.PreviousOut.OutIndex)
+			asm, _ := txscript.DisasmString(in.SignScript)
+			vin.ScriptSig = ScriptSig{Asm: asm, Hex: hex.EncodeToString(in.SignScript)}
+		}
+		rs.Vin = append(rs.Vin, vin)
+	}
+	for _, out := range tx.TxOut {
+		class, addrs, reqSigs, err := txscript.ExtractPkScriptAddrs(out.PkScript, net)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode output of %s; error:%s", rs.Txid, err.Error())
+		}
+		asm, _ := txscript.DisasmString(out.PkScript)
+		addresses := make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			addresses = append(addresses, addr.Encode())
+		}
+		rs.Vout = append(rs.Vout, Vout{
+			Coin:   out.Amount.Id.Name(),
+			CoinId: uint16(out.Amount.Id),
+			Amount: uint64(out.Amount.Value),
+			ScriptPubKey: ScriptPubKey{
+				Asm:       asm,
+				Hex:       hex.EncodeToString(out.PkScript),
+				ReqSigs:   uint64(reqSigs),
+				Type:      class.String(),
+				Addresses: addresses,
+			},
+		})
+	}
+	return rs, nil
+}
@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/params"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testRawBlock(t *testing.T) (*types.Block, string) {
+	pkScript, _ := hex.DecodeString("76a914" + strings.Repeat("ab", 20) + "88ac")
+	coinbase := &types.Transaction{
+		Version: 1,
+		TxIn: []*types.TxInput{{
+			PreviousOut: types.TxOutPoint{OutIndex: 0xffffffff},
+			SignScript:  []byte{0x01, 0x02},
+		}},
+		TxOut:     []*types.TxOutput{{Amount: types.Amount{Value: 1000, Id: types.MEERID}, PkScript: pkScript}},
+		Timestamp: time.Unix(1600000000, 0),
+	}
+	spend := &types.Transaction{
+		Version: 1,
+		TxIn: []*types.TxInput{{
+			PreviousOut: types.TxOutPoint{Hash: coinbase.TxHash(), OutIndex: 0},
+			SignScript:  []byte{0x03},
+		}},
+		TxOut:     []*types.TxOutput{{Amount: types.Amount{Value: 900, Id: types.MEERID}, PkScript: pkScript}},
+		Timestamp: time.Unix(1600000001, 0),
+	}
+	parent := hash.Hash{1}
+	block := &types.Block{
+		Header:       types.BlockHeader{Version: 1, TxRoot: hash.Hash{2}, Timestamp: time.Unix(1600000002, 0)},
+		Parents:      []*hash.Hash{&parent},
+		Transactions: []*types.Transaction{coinbase, spend},
+	}
+	buf := &bytes.Buffer{}
+	if err := block.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	return block, hex.EncodeToString(buf.Bytes())
+}
+
+func TestDecodeBlock(t *testing.T) {
+	block, raw := testRawBlock(t)
+	blk, err := DecodeBlock(raw, &params.TestNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blk.Hash != block.BlockHash().String() || len(blk.ParentHash) != 1 || len(blk.Transactions) != 2 {
+		t.Fatalf("unexpected block %+v", blk)
+	}
+	coinbase, spend := blk.Transactions[0], blk.Transactions[1]
+	if coinbase.Vin[0].Coinbase == "" || coinbase.Vout[0].Amount != 1000 {
+		t.Fatalf("unexpected coinbase %+v", coinbase)
+	}
+	if spend.Vin[0].Txid != coinbase.Txid || spend.Vin[0].Vout != 0 {
+		t.Fatalf("unexpected input %+v", spend.Vin[0])
+	}
+	out := spend.Vout[0]
+	if out.Coin != "MEER" || out.ScriptPubKey.Type != "pubkeyhash" || len(out.ScriptPubKey.Addresses) != 1 {
+		t.Fatalf("unexpected output %+v", out)
+	}
+	if _, err := DecodeBlock("zz", &params.TestNetParams); err == nil {
+		t.Fatal("expected invalid hex error")
+	}
+}
+
+func TestClient_GetRawBlockByOrder(t *testing.T) {
+	block, raw := testRawBlock(t)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		reqs := []*ClientRequest{}
+		json.NewDecoder(r.Body).Decode(&reqs)
+		if len(reqs) != 2 {
+			http.Error(w, "expected a batch", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `[{"id":2,"result":"%s"},{"id":1,"result":{"hash":"%s","order":7,"confirmations":3,"txsvalid":true}}]`,
+			raw, block.BlockHash().String())
+	})
+	if _, err := client.GetRawBlockByOrder(7); err == nil {
+		t.Fatal("expected an error without chain params")
+	}
+	client.rpcCfg.ChainParams = &params.TestNetParams
+	blk, err := client.GetRawBlockByOrder(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blk.Order != 7 || !blk.Txsvalid || len(blk.Transactions) != 2 || blk.Transactions[1].Confirmations != 3 {
+		t.Fatalf("unexpected block %+v", blk)
+	}
+}
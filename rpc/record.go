@@ -0,0 +1,253 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Record is a request sent to a node together with its response,
+// Error is set instead of Response when the node could not be reached
+type Record struct {
+	Address  string          `json:"address"`
+	Request  *ClientRequest  `json:"request"`
+	Response *ClientResponse `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Recorder writes every request of a client and its response
+// as a line of json, batches are split into their requests.
+// Set RpcConfig.WrapTransport to Recorder.Wrap to record a client.
+type Recorder struct {
+	mutex  sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// OpenRecorder appends the records to the file at path
+func OpenRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRecorder(f)
+	r.closer = f
+	return r, nil
+}
+
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Wrap returns a transport recording the traffic of next
+func (r *Recorder) Wrap(next Transport) Transport {
+	return &recordTransport{recorder: r, next: next}
+}
+
+func (r *Recorder) write(records []*Record) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, record := range records {
+		if err := r.enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type recordTransport struct {
+	recorder *Recorder
+	next     Transport
+}
+
+func (t *recordTransport) RoundTrip(ctx context.Context, addr string, body []byte) ([]byte, error) {
+	rs, err := t.next.RoundTrip(ctx, addr, body)
+	if ctx.Err() != nil {
+		// a canceled request says nothing about the node
+		return rs, err
+	}
+	reqs, batch, e := decodeRequests(body)
+	if e != nil {
+		return rs, err
+	}
+	records := make([]*Record, 0, len(reqs))
+	for _, req := range reqs {
+		records = append(records, &Record{Address: addr, Request: req})
+	}
+	if err != nil {
+		for _, record := range records {
+			record.Error = err.Error()
+		}
+	} else {
+		resps, e := decodeResponses(rs, batch)
+		if e != nil {
+			return rs, err
+		}
+		for _, record := range records {
+			record.Response = matchResponse(record.Request, resps)
+		}
+	}
+	if e := t.recorder.write(records); e != nil {
+		return nil, fmt.Errorf("failed to record rpc; error:%s", e.Error())
+	}
+	return rs, err
+}
+
+func (t *recordTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// Replay serves recorded responses instead of calling a node.
+// Requests are matched by method and params, the records of the same
+// request are served in the recorded order and the last one is repeated.
+// Set RpcConfig.WrapTransport to Replay.Wrap to replay a client.
+type Replay struct {
+	mutex   sync.Mutex
+	records map[string][]*Record
+}
+
+func NewReplay(r io.Reader) (*Replay, error) {
+	p := &Replay{records: map[string][]*Record{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record := &Record{}
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return nil, fmt.Errorf("bad record at line %d; error:%s", line, err.Error())
+		}
+		if record.Request == nil {
+			return nil, fmt.Errorf("bad record at line %d; no request", line)
+		}
+		key := recordKey(record.Request)
+		p.records[key] = append(p.records[key], record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadReplay reads the records written by a Recorder to the file at path
+func LoadReplay(path string) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplay(f)
+}
+
+// Wrap returns the replay itself, the wrapped transport is never called
+func (p *Replay) Wrap(Transport) Transport {
+	return p
+}
+
+func (p *Replay) RoundTrip(ctx context.Context, addr string, body []byte) ([]byte, error) {
+	reqs, batch, err := decodeRequests(body)
+	if err != nil {
+		return nil, err
+	}
+	resps := make([]*ClientResponse, 0, len(reqs))
+	for _, req := range reqs {
+		record := p.next(req)
+		if record == nil {
+			return nil, fmt.Errorf("no record of %s %v", req.Method, req.Params)
+		}
+		if record.Error != "" {
+			return nil, fmt.Errorf("%s", record.Error)
+		}
+		resp := *record.Response
+		resp.ID = req.Id
+		resps = append(resps, &resp)
+	}
+	if batch {
+		return json.Marshal(resps)
+	}
+	return json.Marshal(resps[0])
+}
+
+func (p *Replay) next(req *ClientRequest) *Record {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := recordKey(req)
+	records := p.records[key]
+	if len(records) == 0 {
+		return nil
+	}
+	if len(records) > 1 {
+		p.records[key] = records[1:]
+	}
+	return records[0]
+}
+
+func recordKey(req *ClientRequest) string {
+	params, _ := json.Marshal(req.Params)
+	return req.Method + string(params)
+}
+
+func decodeRequests(body []byte) ([]*ClientRequest, bool, error) {
+	if isBatch(body) {
+		reqs := []*ClientRequest{}
+		err := json.Unmarshal(body, &reqs)
+		return reqs, true, err
+	}
+	req := &ClientRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, false, err
+	}
+	return []*ClientRequest{req}, false, nil
+}
+
+// decodeResponses decodes a response body, a batch may also
+// be answered with a single error for all its requests
+func decodeResponses(body []byte, batch bool) ([]*ClientResponse, error) {
+	if batch && isBatch(body) {
+		resps := []*ClientResponse{}
+		err := json.Unmarshal(body, &resps)
+		return resps, err
+	}
+	resp := &ClientResponse{}
+	if err := json.Unmarshal(body, resp); err != nil {
+		return nil, err
+	}
+	return []*ClientResponse{resp}, nil
+}
+
+func matchResponse(req *ClientRequest, resps []*ClientResponse) *ClientResponse {
+	if len(resps) == 1 {
+		return resps[0]
+	}
+	id, _ := json.Marshal(req.Id)
+	for _, resp := range resps {
+		if rid, _ := json.Marshal(resp.ID); bytes.Equal(id, rid) {
+			return resp
+		}
+	}
+	return &ClientResponse{ID: req.Id, Error: &Error{Message: "no response in batch"}}
+}
+
+func isBatch(body []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+}
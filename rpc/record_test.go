@@ -0,0 +1,63 @@
+package rpc_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+)
+
+func TestRecorder_Replay(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(3)
+
+	buf := &bytes.Buffer{}
+	recorder := rpc.NewRecorder(buf)
+	cfg := node.Config()
+	cfg.WrapTransport = recorder.Wrap
+	client := rpc.NewClient(cfg)
+	blocks, err := client.GetBlocksByOrderRange(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetTransaction("unknown"); err == nil {
+		t.Fatal("expected unknown transaction error")
+	}
+	client.Close()
+	node.Close()
+
+	replay, err := rpc.NewReplay(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = node.Config()
+	cfg.WrapTransport = replay.Wrap
+	client = rpc.NewClient(cfg)
+	defer client.Close()
+
+	replayed, err := client.GetBlocksByOrderRangeContext(context.Background(), 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != len(blocks) {
+		t.Fatalf("replayed %d blocks, recorded %d", len(replayed), len(blocks))
+	}
+	for i := range blocks {
+		if replayed[i].Hash != blocks[i].Hash {
+			t.Fatalf("block %d: replayed %s, recorded %s", i, replayed[i].Hash, blocks[i].Hash)
+		}
+	}
+	// a single request is answered from the records of a batch
+	blk, err := client.GetBlockByOrder(2)
+	if err != nil || blk.Hash != blocks[1].Hash {
+		t.Fatalf("replayed block %v, %v", blk, err)
+	}
+	if _, err := client.GetTransaction("unknown"); err == nil {
+		t.Fatal("expected recorded error")
+	}
+	if _, err := client.GetBlockByOrder(100); err == nil {
+		t.Fatal("expected an error for an unrecorded request")
+	}
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 200 * time.Millisecond
+	defaultRetryMaxBackoff     = 10 * time.Second
+	defaultRetryMultiplier     = 2
+	defaultRetryJitter         = 0.2
+)
+
+// idempotentMethods are the rpc methods which are safe to send again
+// after the node could not be reached
+var idempotentMethods = map[string]bool{
+	"getBlockByOrder":   true,
+	"getBlockByID":      true,
+	"getBlock":          true,
+	"getBlockCount":     true,
+	"getRawTransaction": true,
+	"getMempool":        true,
+	"getNodeInfo":       true,
+	"isBlue":            true,
+	"getBlockHeader":    true,
+	"getBlockhash":      true,
+	"getBestBlockHash":  true,
+	"getUtxo":           true,
+	"estimateFee":       true,
+	"getPeerInfo":       true,
+	"getNetworkInfo":    true,
+}
+
+// RetryPolicy retries calls with exponential backoff and jitter.
+// Only transport errors are retried, an error returned by the node is final.
+type RetryPolicy struct {
+	// MaxAttempts is the number of tries including the first one, 1 disables retrying
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff of any attempt, the default of 10s is used when it is 0
+	MaxBackoff time.Duration
+	Multiplier float64
+	// Jitter is the fraction of the backoff which is randomized, between 0 and 1
+	Jitter float64
+	// Retryable classifies the methods which are safe to retry,
+	// the idempotent read methods are retried when it is nil
+	Retryable func(method string) bool
+}
+
+// DefaultRetryPolicy returns the policy used when none is configured
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    defaultRetryMaxAttempts,
+		InitialBackoff: defaultRetryInitialBackoff,
+		MaxBackoff:     defaultRetryMaxBackoff,
+		Multiplier:     defaultRetryMultiplier,
+		Jitter:         defaultRetryJitter,
+	}
+}
+
+// CanRetry reports whether method may be sent again
+func (p *RetryPolicy) CanRetry(method string) bool {
+	if p.Retryable != nil {
+		return p.Retryable(method)
+	}
+	return idempotentMethods[method]
+}
+
+// Backoff returns how long to wait before the retry following the attempt-th failure, starting at 1
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = defaultRetryMaxBackoff
+	}
+	// math.Pow grows to +Inf for many attempts, which the cap also covers
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if backoff > float64(maxBackoff) {
+		backoff = float64(maxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		backoff -= backoff * jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// Wait sleeps for the backoff of attempt, it returns early with the error of ctx
+func (p *RetryPolicy) Wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(p.Backoff(attempt))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
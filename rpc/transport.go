@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Transport sends a json encoded request to the node at addr
+// and returns the body of the response
+type Transport interface {
+	RoundTrip(ctx context.Context, addr string, body []byte) ([]byte, error)
+}
+
+type httpTransport struct {
+	rpcCfg *RpcConfig
+	client *http.Client
+}
+
+func newHttpTransport(cfg *RpcConfig) (*httpTransport, error) {
+	tlsCfg, err := newTlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   cfg.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	tr := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSClientConfig:     tlsCfg,
+		TLSHandshakeTimeout: cfg.ConnectTimeout,
+		MaxIdleConns:        16,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	return &httpTransport{
+		rpcCfg: cfg,
+		client: &http.Client{Transport: tr, Timeout: cfg.ReadTimeout},
+	}, nil
+}
+
+func (t *httpTransport) RoundTrip(ctx context.Context, addr string, body []byte) ([]byte, error) {
+	httpUrl := "http://"
+	if t.rpcCfg.Https {
+		httpUrl = "https://"
+	}
+
+	httpRequest, err :=
+		http.NewRequestWithContext(ctx, http.MethodPost, httpUrl+addr, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("rpc client create request failed; error:%s ", err.Error())
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.SetBasicAuth(t.rpcCfg.User, t.rpcCfg.Pwd)
+
+	response, err := t.client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body; error:%s", err.Error())
+	}
+	return bodyBytes, nil
+}
+
+func (t *httpTransport) CloseIdleConnections() {
+	t.client.CloseIdleConnections()
+}
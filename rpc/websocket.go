@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/gorilla/websocket"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// the largest notification read from the node
+const wsMaxMessageSize = 32 << 20
+
+// dialWebsocket opens a websocket to u, a ws:// or wss:// url. The connection
+// is closed once ctx is done, also while the handshake is still running.
+func dialWebsocket(ctx context.Context, u *url.URL, header http.Header, connectTimeout time.Duration, tlsCfg *tls.Config) (*websocket.Conn, error) {
+	netDialer := &net.Dialer{Timeout: connectTimeout}
+	dialer := &websocket.Dialer{
+		// the context of the dialer ends with the handshake, the one of the socket with ctx
+		NetDialContext: func(dialCtx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := netDialer.DialContext(dialCtx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			go func() {
+				<-ctx.Done()
+				conn.Close()
+			}()
+			return conn, nil
+		},
+		TLSClientConfig:  tlsCfg,
+		HandshakeTimeout: connectTimeout,
+	}
+	ws, resp, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("websocket handshake with %s failed with %s", u.Host, resp.Status)
+		}
+		return nil, err
+	}
+	ws.SetReadLimit(wsMaxMessageSize)
+	return ws, nil
+}
+
+// wsUrl builds the websocket url of the node at addr
+func wsUrl(addr string, https bool, path string) (*url.URL, error) {
+	scheme := "ws"
+	if https {
+		scheme = "wss"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return url.Parse(scheme + "://" + addr + path)
+}
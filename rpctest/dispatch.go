@@ -0,0 +1,244 @@
+package rpctest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"strings"
+)
+
+func errInvalidParams(method string) *rpc.Error {
+	return &rpc.Error{Code: -32602, Message: fmt.Sprintf("invalid params of %s", method)}
+}
+
+func (n *Node) dispatch(method string, params []interface{}) (interface{}, *rpc.Error) {
+	switch method {
+	case "getBlockByOrder", "getBlockByID":
+		order, ok := uintParam(params, 0)
+		if !ok {
+			return nil, errInvalidParams(method)
+		}
+		if order >= uint64(len(n.blocks)) {
+			return nil, &rpc.Error{Code: rpc.ErrCodeInvalidAddressOrKey, Message: fmt.Sprintf("Block not found: %d", order)}
+		}
+		rs := n.blockResult(n.blocks[order])
+		// verbose blocks without their transactions
+		if len(params) > 2 && !boolParam(params, 2) {
+			rs.Transactions = nil
+		}
+		return rs, nil
+	case "getBlock":
+		hash, ok := stringParam(params, 0)
+		if !ok {
+			return nil, errInvalidParams(method)
+		}
+		blk, ok := n.byHash[hash]
+		if !ok {
+			return nil, &rpc.Error{Code: rpc.ErrCodeInvalidAddressOrKey, Message: fmt.Sprintf("Block not found: %s", hash)}
+		}
+		return n.blockResult(blk), nil
+	case "isBlue":
+		hash, ok := stringParam(params, 0)
+		if !ok {
+			return nil, errInvalidParams(method)
+		}
+		if _, ok := n.byHash[hash]; !ok {
+			return nil, &rpc.Error{Code: rpc.ErrCodeInvalidAddressOrKey, Message: fmt.Sprintf("Block not found: %s", hash)}
+		}
+		if n.blue[hash] {
+			return 1, nil
+		}
+		return 0, nil
+	case "getNodeInfo":
+		tip := n.blocks[len(n.blocks)-1]
+		return &rpc.NodeInfo{
+			Version:          10006,
+			BuildVersion:     n.BuildVersion,
+			ProtocolVersion:  32,
+			Network:          n.Network,
+			Confirmations:    n.Confirmations,
+			Coinbasematurity: n.CoinbaseMaturity,
+			GraphState: rpc.GraphState{
+				Tips:       []string{tip.Hash},
+				Mainorder:  tip.Order,
+				Layer:      tip.Height,
+				MainHeight: tip.Height,
+			},
+		}, nil
+	case "getBlockCount":
+		return len(n.blocks), nil
+	case "getRawTransaction":
+		txid, ok := stringParam(params, 0)
+		if !ok {
+			return nil, errInvalidParams(method)
+		}
+		if tx, ok := n.mempool[txid]; ok {
+			return tx, nil
+		}
+		tx, ok := n.txs[txid]
+		if !ok {
+			return nil, &rpc.Error{Code: rpc.ErrCodeInvalidAddressOrKey, Message: "No information available about transaction " + txid}
+		}
+		rs := *tx
+		if blk, ok := n.byHash[tx.Blockhash]; ok {
+			rs.Confirmations = n.confirmations(blk)
+		}
+		return &rs, nil
+	case "sendRawTransaction":
+		raw, ok := stringParam(params, 0)
+		if !ok || len(raw) == 0 {
+			return nil, errInvalidParams(method)
+		}
+		h := sha256.Sum256([]byte(strings.TrimSpace(raw)))
+		txid := hex.EncodeToString(h[:])
+		if _, ok := n.mempool[txid]; ok {
+			return nil, &rpc.Error{Code: -40, Message: "already have transaction " + txid}
+		}
+		n.mempool[txid] = &rpc.Transaction{Hex: raw, Txid: txid}
+		return txid, nil
+	case "getMempool":
+		if boolParam(params, 1) {
+			rs := map[string]*rpc.MempoolEntry{}
+			for txid, tx := range n.mempool {
+				rs[txid] = &rpc.MempoolEntry{
+					Size:   int32(len(tx.Hex) / 2),
+					Time:   tx.Timestamp.Unix(),
+					Height: uint64(len(n.blocks) - 1),
+				}
+			}
+			return rs, nil
+		}
+		txids := make([]string, 0, len(n.mempool))
+		for txid := range n.mempool {
+			txids = append(txids, txid)
+		}
+		return txids, nil
+	case "getBlockHeader":
+		hash, ok := stringParam(params, 0)
+		if !ok {
+			return nil, errInvalidParams(method)
+		}
+		blk, ok := n.byHash[hash]
+		if !ok {
+			return nil, &rpc.Error{Code: rpc.ErrCodeInvalidAddressOrKey, Message: fmt.Sprintf("Block not found: %s", hash)}
+		}
+		return &rpc.BlockHeader{
+			Hash:          blk.Hash,
+			Confirmations: n.confirmations(blk),
+			Version:       blk.Version,
+			TxRoot:        blk.TxRoot,
+			StateRoot:     blk.StateRoot,
+			Difficulty:    blk.Difficulty,
+			Layer:         blk.Height,
+			Timestamp:     blk.Timestamp,
+		}, nil
+	case "getBlockhash":
+		order, ok := uintParam(params, 0)
+		if !ok {
+			return nil, errInvalidParams(method)
+		}
+		if order >= uint64(len(n.blocks)) {
+			return nil, &rpc.Error{Code: rpc.ErrCodeInvalidAddressOrKey, Message: fmt.Sprintf("Block not found: %d", order)}
+		}
+		return n.blocks[order].Hash, nil
+	case "getBestBlockHash":
+		return n.blocks[len(n.blocks)-1].Hash, nil
+	case "getUtxo":
+		txid, ok := stringParam(params, 0)
+		vout, ok2 := uintParam(params, 1)
+		if !ok || !ok2 {
+			return nil, errInvalidParams(method)
+		}
+		return n.utxo(txid, vout, boolParam(params, 2)), nil
+	case "estimateFee":
+		return n.FeeRate, nil
+	case "getPeerInfo":
+		if n.Peers == nil {
+			return []*rpc.PeerInfo{}, nil
+		}
+		return n.Peers, nil
+	case "getNetworkInfo":
+		connected := 0
+		for _, peer := range n.Peers {
+			if peer.State {
+				connected++
+			}
+		}
+		return &rpc.NetworkInfo{Peers: len(n.Peers), Connected: connected, Network: "testnet"}, nil
+	}
+	return nil, &rpc.Error{Code: -32601, Message: fmt.Sprintf("Method not found: %s", method)}
+}
+
+// utxo returns output vout of txid, nil if it is spent or unknown
+func (n *Node) utxo(txid string, vout uint64, includeMempool bool) *rpc.TxOut {
+	tx, ok := n.txs[txid]
+	if !ok || vout >= uint64(len(tx.Vout)) {
+		return nil
+	}
+	spends := func(txs map[string]*rpc.Transaction) bool {
+		for _, t := range txs {
+			for _, vin := range t.Vin {
+				if vin.Txid == txid && vin.Vout == vout {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if spends(n.txs) || includeMempool && spends(n.mempool) {
+		return nil
+	}
+	out := tx.Vout[vout]
+	return &rpc.TxOut{
+		BestBlock:     n.blocks[len(n.blocks)-1].Hash,
+		Confirmations: n.confirmations(n.byHash[tx.Blockhash]),
+		CoinId:        out.CoinId,
+		Amount:        out.Amount,
+		ScriptPubKey:  out.ScriptPubKey,
+		Coinbase:      len(tx.Vin) > 0 && tx.Vin[0].Coinbase != "",
+	}
+}
+
+// blockResult returns a copy of blk as the node reports it at this moment
+func (n *Node) blockResult(blk *rpc.Block) *rpc.Block {
+	rs := *blk
+	rs.Confirmations = n.confirmations(blk)
+	rs.Transactions = make([]rpc.Transaction, len(blk.Transactions))
+	copy(rs.Transactions, blk.Transactions)
+	for i := range rs.Transactions {
+		rs.Transactions[i].Confirmations = rs.Confirmations
+	}
+	return &rs
+}
+
+func (n *Node) confirmations(blk *rpc.Block) uint32 {
+	return uint32(uint64(len(n.blocks)-1) - blk.Order)
+}
+
+func uintParam(params []interface{}, i int) (uint64, bool) {
+	if len(params) <= i {
+		return 0, false
+	}
+	v, ok := params[i].(float64)
+	if !ok || v < 0 {
+		return 0, false
+	}
+	return uint64(v), true
+}
+
+func boolParam(params []interface{}, i int) bool {
+	if len(params) <= i {
+		return false
+	}
+	v, _ := params[i].(bool)
+	return v
+}
+
+func stringParam(params []interface{}, i int) (string, bool) {
+	if len(params) <= i {
+		return "", false
+	}
+	v, ok := params[i].(string)
+	return v, ok
+}
@@ -0,0 +1,326 @@
+// Package rpctest provides an in-process fake qitmeer node for tests.
+// The node keeps a scriptable in-memory dag and serves the json-rpc
+// methods used by the rpc client over httptest.
+package rpctest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConfirmations    = 10
+	defaultCoinbaseMaturity = 720
+	defaultFeeRate          = 10000
+	coinbaseAmount          = 1000000000
+)
+
+// Node is a fake qitmeer node, all its methods are safe for concurrent use
+type Node struct {
+	// node info returned by getNodeInfo
+	Confirmations    uint32
+	CoinbaseMaturity uint32
+	// network and version returned by getNodeInfo
+	Network      string
+	BuildVersion string
+	// FeeRate is returned by estimateFee, Peers by getPeerInfo
+	FeeRate uint64
+	Peers   []*rpc.PeerInfo
+	// Latency delays every http request, set it before the node is used
+	Latency time.Duration
+
+	mutex   sync.Mutex
+	server  *httptest.Server
+	blocks  []*rpc.Block
+	byHash  map[string]*rpc.Block
+	blue    map[string]bool
+	txs     map[string]*rpc.Transaction
+	mempool map[string]*rpc.Transaction
+	errs    map[string]*injectedError
+	calls   map[string]int
+	nonce   uint64
+}
+
+type injectedError struct {
+	err   *rpc.Error
+	count int
+}
+
+// NewNode starts a node with the genesis block at order 0
+func NewNode() *Node {
+	n := &Node{
+		Confirmations:    defaultConfirmations,
+		CoinbaseMaturity: defaultCoinbaseMaturity,
+		Network:          "testnet",
+		BuildVersion:     "0.10.6",
+		FeeRate:          defaultFeeRate,
+		byHash:           map[string]*rpc.Block{},
+		blue:             map[string]bool{},
+		txs:              map[string]*rpc.Transaction{},
+		mempool:          map[string]*rpc.Transaction{},
+		errs:             map[string]*injectedError{},
+		calls:            map[string]int{},
+	}
+	n.mineBlock(nil)
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+	return n
+}
+
+// Close shuts the node down
+func (n *Node) Close() {
+	n.server.Close()
+}
+
+// Addr returns the host:port the node listens on
+func (n *Node) Addr() string {
+	return strings.TrimPrefix(n.server.URL, "http://")
+}
+
+// Config returns a rpc config pointing to the node
+func (n *Node) Config() *rpc.RpcConfig {
+	return &rpc.RpcConfig{Address: n.Addr(), User: "test", Pwd: "test"}
+}
+
+// MineBlock adds a blue block with a coinbase and txs on top of the current tip.
+// The txs are removed from the mempool, the txs mined before are marked Duplicate.
+func (n *Node) MineBlock(txs ...*rpc.Transaction) *rpc.Block {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.mineBlock(txs)
+}
+
+// Mine adds count empty blocks
+func (n *Node) Mine(count int) []*rpc.Block {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	blocks := make([]*rpc.Block, 0, count)
+	for i := 0; i < count; i++ {
+		blocks = append(blocks, n.mineBlock(nil))
+	}
+	return blocks
+}
+
+func (n *Node) mineBlock(txs []*rpc.Transaction) *rpc.Block {
+	order := uint64(len(n.blocks))
+	blk := &rpc.Block{
+		Id:        order,
+		Hash:      n.newHash("block"),
+		Txsvalid:  true,
+		Order:     order,
+		Height:    order,
+		Timestamp: time.Unix(1600000000+int64(order), 0).UTC(),
+	}
+	if order > 0 {
+		parent := n.blocks[order-1]
+		blk.ParentHash = []string{parent.Hash}
+		parent.ChildrenHash = append(parent.ChildrenHash, blk.Hash)
+	}
+	coinbase := &rpc.Transaction{
+		Txid: n.newHash("coinbase"),
+		Vin:  []rpc.Vin{{Coinbase: "00"}},
+		Vout: []rpc.Vout{Output("TmMinerAddress", coinbaseAmount)},
+	}
+	for _, tx := range append([]*rpc.Transaction{coinbase}, txs...) {
+		if _, ok := n.txs[tx.Txid]; ok {
+			// a transaction already in the dag is a duplicate in this block
+			dup := *tx
+			dup.Blockhash = blk.Hash
+			dup.Timestamp = blk.Timestamp
+			dup.Duplicate = true
+			blk.Transactions = append(blk.Transactions, dup)
+			continue
+		}
+		tx.Blockhash = blk.Hash
+		tx.Timestamp = blk.Timestamp
+		n.txs[tx.Txid] = tx
+		delete(n.mempool, tx.Txid)
+		blk.Transactions = append(blk.Transactions, *tx)
+	}
+	n.blocks = append(n.blocks, blk)
+	n.byHash[blk.Hash] = blk
+	n.blue[blk.Hash] = true
+	return blk
+}
+
+func (n *Node) newHash(kind string) string {
+	n.nonce++
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s-%d", kind, n.nonce)))
+	return hex.EncodeToString(h[:])
+}
+
+// Block returns a copy of the block at order, nil if there is none
+func (n *Node) Block(order uint64) *rpc.Block {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if order >= uint64(len(n.blocks)) {
+		return nil
+	}
+	return n.blockResult(n.blocks[order])
+}
+
+// MainOrder returns the order of the tip
+func (n *Node) MainOrder() uint64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return uint64(len(n.blocks) - 1)
+}
+
+// SetBlue changes the color of a block
+func (n *Node) SetBlue(hash string, blue bool) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.blue[hash] = blue
+}
+
+// Invalidate marks the transactions of a block as invalid
+func (n *Node) Invalidate(hash string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if blk, ok := n.byHash[hash]; ok {
+		blk.Txsvalid = false
+	}
+}
+
+// ReplaceBlock puts a new block with txs at order, as if the dag was reorganized.
+// The blocks after it keep their order and refer to the new block instead, the
+// old block is still returned by its hash.
+func (n *Node) ReplaceBlock(order uint64, txs ...*rpc.Transaction) *rpc.Block {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	old := n.blocks[order]
+	for _, tx := range old.Transactions {
+		if !tx.Duplicate {
+			delete(n.txs, tx.Txid)
+		}
+	}
+	tail := n.blocks[order:]
+	n.blocks = n.blocks[:order]
+	blk := n.mineBlock(txs)
+	blk.ParentHash = old.ParentHash
+	blk.Height = old.Height
+	n.blocks = append(n.blocks, tail[1:]...)
+	for _, b := range tail[1:] {
+		for i, parent := range b.ParentHash {
+			if parent == old.Hash {
+				b.ParentHash[i] = blk.Hash
+			}
+		}
+	}
+	return n.blockResult(blk)
+}
+
+// AddToMempool puts tx into the mempool
+func (n *Node) AddToMempool(tx *rpc.Transaction) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.mempool[tx.Txid] = tx
+}
+
+// DropFromMempool removes tx from the mempool without mining it
+func (n *Node) DropFromMempool(txid string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	delete(n.mempool, txid)
+}
+
+// InjectError makes the next count calls of method fail with err, forever if count is negative
+func (n *Node) InjectError(method string, count int, err *rpc.Error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.errs[method] = &injectedError{err: err, count: count}
+}
+
+// ClearErrors removes all injected errors
+func (n *Node) ClearErrors() {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.errs = map[string]*injectedError{}
+}
+
+// Calls returns how many times method was called
+func (n *Node) Calls(method string) int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.calls[method]
+}
+
+func (n *Node) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if n.Latency > 0 {
+		time.Sleep(n.Latency)
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result interface{}
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		reqs := []*rpc.ClientRequest{}
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resps := make([]*rpc.ClientResponse, 0, len(reqs))
+		for _, req := range reqs {
+			resps = append(resps, n.handle(req))
+		}
+		result = resps
+	} else {
+		req := &rpc.ClientRequest{}
+		if err := json.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result = n.handle(req)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (n *Node) handle(req *rpc.ClientRequest) *rpc.ClientResponse {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.calls[req.Method]++
+	resp := &rpc.ClientResponse{ID: req.Id}
+	if injected, ok := n.errs[req.Method]; ok && injected.count != 0 {
+		if injected.count > 0 {
+			injected.count--
+		}
+		resp.Error = injected.err
+		return resp
+	}
+	result, rpcErr := n.dispatch(req.Method, req.Params)
+	if rpcErr != nil {
+		resp.Error = rpcErr
+		return resp
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		resp.Error = &rpc.Error{Code: -32603, Message: err.Error()}
+		return resp
+	}
+	resp.Result = data
+	return resp
+}
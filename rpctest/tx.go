@@ -0,0 +1,47 @@
+package rpctest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"sync/atomic"
+)
+
+var txNonce uint64
+
+// NewTransaction builds a transaction with a unique txid
+func NewTransaction(vin []rpc.Vin, vout []rpc.Vout) *rpc.Transaction {
+	data, _ := json.Marshal(struct {
+		Nonce uint64
+		Vin   []rpc.Vin
+		Vout  []rpc.Vout
+	}{atomic.AddUint64(&txNonce, 1), vin, vout})
+	h := sha256.Sum256(data)
+	return &rpc.Transaction{
+		Txid:    hex.EncodeToString(h[:]),
+		Version: 1,
+		Vin:     vin,
+		Vout:    vout,
+	}
+}
+
+// Input spends output vout of txid
+func Input(txid string, vout uint64) rpc.Vin {
+	return rpc.Vin{Txid: txid, Vout: vout}
+}
+
+// Output pays amount MEER to a pay-to-pubkey-hash address
+func Output(address string, amount uint64) rpc.Vout {
+	h := sha256.Sum256([]byte(address))
+	return rpc.Vout{
+		Coin:   "MEER",
+		Amount: amount,
+		ScriptPubKey: rpc.ScriptPubKey{
+			Hex:       "76a914" + hex.EncodeToString(h[:20]) + "88ac",
+			ReqSigs:   1,
+			Type:      "pubkeyhash",
+			Addresses: []string{address},
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+)
+
+const (
+	// processed orders whose hashes are kept
+	defaultHashWindow = 1000
+	// parents are checked once this many orders before the block are known,
+	// a block whose parents are all older than that is not expected
+	defaultParentWindow = 100
+)
+
+// ErrChainMismatch is returned when the node serves blocks
+// which do not continue the synchronized history
+var ErrChainMismatch = errors.New("block does not continue the synchronized chain")
+
+// hashWindow keeps the hashes of the recently processed orders
+type hashWindow struct {
+	size   uint64
+	orders map[uint64]string
+	hashes map[string]uint64
+}
+
+func newHashWindow(size uint64) *hashWindow {
+	return &hashWindow{
+		size:   size,
+		orders: map[uint64]string{},
+		hashes: map[string]uint64{},
+	}
+}
+
+func (w *hashWindow) add(order uint64, hash string) {
+	if old, ok := w.orders[order]; ok {
+		delete(w.hashes, old)
+	}
+	w.orders[order] = hash
+	w.hashes[hash] = order
+	if order >= w.size {
+		if old, ok := w.orders[order-w.size]; ok {
+			delete(w.orders, order-w.size)
+			delete(w.hashes, old)
+		}
+	}
+}
+
+// truncate forgets the orders from order on
+func (w *hashWindow) truncate(order uint64) {
+	for o, hash := range w.orders {
+		if o >= order {
+			delete(w.orders, o)
+			delete(w.hashes, hash)
+		}
+	}
+}
+
+func (w *hashWindow) hash(order uint64) (string, bool) {
+	hash, ok := w.orders[order]
+	return hash, ok
+}
+
+// known reports whether the count orders before order are all in the window
+func (w *hashWindow) known(order uint64, count uint64) bool {
+	if order < count {
+		count = order
+	}
+	for i := uint64(1); i <= count; i++ {
+		if _, ok := w.orders[order-i]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// verify checks that block continues the processed blocks. The hash of an order
+// already processed must not change and once enough orders before the block are
+// known, at least one of its parents has to be among them.
+func (w *hashWindow) verify(block *rpc.Block) error {
+	if hash, ok := w.orders[block.Order]; ok && hash != block.Hash {
+		return fmt.Errorf("%w, order %d was %s and is now %s", ErrChainMismatch, block.Order, hash, block.Hash)
+	}
+	if block.Order == 0 || !w.known(block.Order, defaultParentWindow) {
+		return nil
+	}
+	for _, parent := range block.ParentHash {
+		if _, ok := w.hashes[parent]; ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w, no parent of block %s at order %d was synchronized", ErrChainMismatch, block.Hash, block.Order)
+}
+
+// verifyHistory checks that the node still has the last block of a previous sync,
+// with the reorg window a replaced block is rolled back instead, see loadReplacedBlock
+func (s *Synchronizer) verifyHistory(info *HistoryOrder) error {
+	if info.LastBlockHash == "" {
+		return nil
+	}
+	hash, err := s.rpcClient.GetBlockHashContext(s.ctx, info.LastTxBlockOrder)
+	if err != nil {
+		return fmt.Errorf("failed to get block %d, %s", info.LastTxBlockOrder, err.Error())
+	}
+	if hash != info.LastBlockHash {
+		return fmt.Errorf("%w, order %d was %s and is now %s", ErrChainMismatch, info.LastTxBlockOrder, info.LastBlockHash, hash)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+)
+
+func TestHashWindow_Verify(t *testing.T) {
+	w := newHashWindow(defaultHashWindow)
+	for order := uint64(0); order < defaultParentWindow+1; order++ {
+		w.add(order, fmt.Sprintf("hash-%d", order))
+	}
+	order := uint64(defaultParentWindow + 1)
+	if err := w.verify(&rpc.Block{Order: order, Hash: "next", ParentHash: []string{"hash-3", "unknown"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.verify(&rpc.Block{Order: order, Hash: "next", ParentHash: []string{"unknown"}}); !errors.Is(err, ErrChainMismatch) {
+		t.Fatalf("expected a chain mismatch for unknown parents, got %v", err)
+	}
+	if err := w.verify(&rpc.Block{Order: 5, Hash: "other"}); !errors.Is(err, ErrChainMismatch) {
+		t.Fatalf("expected a chain mismatch for a changed order, got %v", err)
+	}
+
+	w = newHashWindow(10)
+	for order := uint64(0); order < 20; order++ {
+		w.add(order, fmt.Sprintf("hash-%d", order))
+	}
+	if _, ok := w.hash(9); ok || len(w.orders) != 10 || len(w.hashes) != 10 {
+		t.Fatalf("expected the window to keep the last 10 orders, got %d", len(w.orders))
+	}
+}
+
+func TestSynchronizer_ResumeOnOtherChain(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(5)
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiveTxs(t, txChan, 3)
+	synchronizer.Stop()
+	history := synchronizer.GetHistoryOrder()
+	if history.LastBlockHash != node.Block(history.LastTxBlockOrder).Hash {
+		t.Fatalf("unexpected history %+v", history)
+	}
+
+	history.Confirmations = 1
+	synchronizer = newTestSynchronizer(node)
+	if _, err := synchronizer.Start(history); err != nil {
+		t.Fatal(err)
+	}
+	synchronizer.Stop()
+
+	node.ReplaceBlock(history.LastTxBlockOrder)
+	synchronizer = newTestSynchronizer(node)
+	if _, err := synchronizer.Start(history); !errors.Is(err, ErrChainMismatch) {
+		t.Fatalf("expected a chain mismatch, got %v", err)
+	}
+}
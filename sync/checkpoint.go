@@ -0,0 +1,165 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"io/ioutil"
+	"os"
+)
+
+// CheckpointStore keeps the last block acknowledged by the consumer, see Synchronizer.Ack.
+// Start resumes from the stored checkpoint instead of the given order.
+type CheckpointStore interface {
+	// Load returns the stored checkpoint, nil if there is none
+	Load() (*HistoryOrder, error)
+	Save(checkpoint *HistoryOrder) error
+}
+
+// Ack marks the blocks up to order as consumed, the checkpoint is saved to
+// Options.Checkpoint. The synchronizer resumes with the block at the checkpoint,
+// so every block is sent at least once.
+func (s *Synchronizer) Ack(order uint64) error {
+	s.ackMutex.Lock()
+	defer s.ackMutex.Unlock()
+
+	s.mutex.RLock()
+	if order > s.lastOrder {
+		s.mutex.RUnlock()
+		return fmt.Errorf("block %d was not sent yet", order)
+	}
+	hash, _ := s.hashes.hash(order)
+	s.mutex.RUnlock()
+
+	return s.saveCheckpoint(&HistoryOrder{LastTxBlockOrder: order, LastBlockHash: hash})
+}
+
+// Checkpoint returns the last acknowledged block, nil before the first Ack
+func (s *Synchronizer) Checkpoint() *HistoryOrder {
+	s.ackMutex.Lock()
+	defer s.ackMutex.Unlock()
+
+	if s.checkpoint == nil {
+		return nil
+	}
+	checkpoint := *s.checkpoint
+	return &checkpoint
+}
+
+func (s *Synchronizer) saveCheckpoint(checkpoint *HistoryOrder) error {
+	if s.opt.Checkpoint != nil {
+		if err := s.opt.Checkpoint.Save(checkpoint); err != nil {
+			return fmt.Errorf("failed to save checkpoint, %s", err.Error())
+		}
+	}
+	s.checkpoint = checkpoint
+	return nil
+}
+
+// loadCheckpoint returns info with the order of the stored checkpoint, if any
+func (s *Synchronizer) loadCheckpoint(info *HistoryOrder) (*HistoryOrder, error) {
+	if s.opt.Checkpoint == nil {
+		return info, nil
+	}
+	checkpoint, err := s.opt.Checkpoint.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint, %s", err.Error())
+	}
+	if checkpoint == nil {
+		return info, nil
+	}
+	s.ackMutex.Lock()
+	s.checkpoint = checkpoint
+	s.ackMutex.Unlock()
+
+	return &HistoryOrder{
+		LastTxBlockOrder: checkpoint.LastTxBlockOrder,
+		LastBlockHash:    checkpoint.LastBlockHash,
+		Confirmations:    info.Confirmations,
+	}, nil
+}
+
+// rewindCheckpoint moves the checkpoint before a rolled back block, the block
+// at the old checkpoint does not exist anymore
+func (s *Synchronizer) rewindCheckpoint(order uint64, hash string) {
+	s.ackMutex.Lock()
+	defer s.ackMutex.Unlock()
+
+	if s.checkpoint == nil || s.checkpoint.LastTxBlockOrder <= order {
+		return
+	}
+	if err := s.saveCheckpoint(&HistoryOrder{LastTxBlockOrder: order, LastBlockHash: hash}); err != nil {
+		s.report(&SyncError{Kind: ErrorCheckpoint, Order: order, Hash: hash, Err: err, Warning: true})
+	}
+}
+
+// FileCheckpoint stores the checkpoint as json in a file
+type FileCheckpoint struct {
+	path string
+}
+
+func NewFileCheckpoint(path string) *FileCheckpoint {
+	return &FileCheckpoint{path: path}
+}
+
+func (f *FileCheckpoint) Load() (*HistoryOrder, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &HistoryOrder{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (f *FileCheckpoint) Save(checkpoint *HistoryOrder) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	// a crash while writing must not leave a broken checkpoint behind
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// LevelDBCheckpoint stores the checkpoint under a key of a leveldb
+type LevelDBCheckpoint struct {
+	db  *leveldb.DB
+	key []byte
+}
+
+func NewLevelDBCheckpoint(db *leveldb.DB, key string) *LevelDBCheckpoint {
+	return &LevelDBCheckpoint{db: db, key: []byte(key)}
+}
+
+func (l *LevelDBCheckpoint) Load() (*HistoryOrder, error) {
+	data, err := l.db.Get(l.key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &HistoryOrder{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (l *LevelDBCheckpoint) Save(checkpoint *HistoryOrder) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return l.db.Put(l.key, data, &opt.WriteOptions{Sync: true})
+}
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func testCheckpointStore(t *testing.T, store CheckpointStore) {
+	checkpoint, err := store.Load()
+	if err != nil || checkpoint != nil {
+		t.Fatalf("expected no checkpoint, got %+v %v", checkpoint, err)
+	}
+	saved := &HistoryOrder{LastTxBlockOrder: 12, LastBlockHash: "abcd"}
+	if err := store.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err = store.Load()
+	if err != nil || !reflect.DeepEqual(checkpoint, saved) {
+		t.Fatalf("expected %+v, got %+v %v", saved, checkpoint, err)
+	}
+}
+
+func TestFileCheckpoint(t *testing.T) {
+	testCheckpointStore(t, NewFileCheckpoint(filepath.Join(t.TempDir(), "checkpoint")))
+}
+
+func TestLevelDBCheckpoint(t *testing.T) {
+	db, err := leveldb.OpenFile(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	testCheckpointStore(t, NewLevelDBCheckpoint(db, "checkpoint"))
+}
+
+func TestSynchronizer_AckResume(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	for i := 0; i < 5; i++ {
+		node.MineBlock(rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)}))
+	}
+	node.Mine(1)
+	store := NewFileCheckpoint(filepath.Join(t.TempDir(), "checkpoint"))
+
+	start := func() (*Synchronizer, <-chan *BlockEvent) {
+		opt := newTestOptions(node)
+		opt.ReorgWindow = 5
+		opt.Checkpoint = store
+		synchronizer := NewSynchronizer(opt)
+		events, err := synchronizer.StartEvents(&HistoryOrder{Confirmations: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return synchronizer, events
+	}
+	synchronizer, events := start()
+	evs := receiveEvents(t, events, 5)
+	if err := synchronizer.Ack(100); err == nil {
+		t.Fatal("expected an error for a block which was not sent")
+	}
+	// the consumer has taken the blocks up to 3 only
+	if err := synchronizer.Ack(3); err != nil {
+		t.Fatal(err)
+	}
+	synchronizer.Stop()
+	if evs[len(evs)-1].Order != 4 {
+		t.Fatalf("expected the blocks up to 4, got %d", evs[len(evs)-1].Order)
+	}
+
+	synchronizer, events = start()
+	defer synchronizer.Stop()
+	evs = receiveEvents(t, events, 1)
+	if evs[0].Order != 3 {
+		t.Fatalf("expected to resume at block 3, got %d", evs[0].Order)
+	}
+	if checkpoint := synchronizer.Checkpoint(); checkpoint.LastTxBlockOrder != 3 || checkpoint.LastBlockHash != node.Block(3).Hash {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+}
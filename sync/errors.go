@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bCoder778/log"
+	"time"
+)
+
+const (
+	defaultErrChLen     = 100
+	defaultStuckTimeout = 10 * time.Minute
+)
+
+// ErrUnknownColor is returned for a block which is neither blue nor red
+var ErrUnknownColor = errors.New("unknown block color")
+
+// SyncErrorKind tells which step of the synchronization failed
+type SyncErrorKind string
+
+const (
+	// the block could not be fetched
+	ErrorFetch SyncErrorKind = "fetch"
+	// the block does not continue the synchronized blocks, see ErrChainMismatch
+	ErrorChain SyncErrorKind = "chain"
+	// the color of the block could not be fetched or is unknown
+	ErrorColor SyncErrorKind = "color"
+	// the recent blocks could not be checked for a dag reorganization
+	ErrorRecheck SyncErrorKind = "recheck"
+	// the checkpoint could not be saved
+	ErrorCheckpoint SyncErrorKind = "checkpoint"
+	// no block was processed for Options.StuckTimeout
+	ErrorStuck SyncErrorKind = "stuck"
+)
+
+// SyncError is a failure of the sync loop at a block, the loop keeps retrying
+// the block unless it is a Warning, then it carried on
+type SyncError struct {
+	Kind    SyncErrorKind
+	Order   uint64
+	Hash    string
+	Err     error
+	Time    time.Time
+	Warning bool
+}
+
+func (e *SyncError) Error() string {
+	if e.Hash != "" {
+		return fmt.Sprintf("%s error at block %d %s, %s", e.Kind, e.Order, e.Hash, e.Err.Error())
+	}
+	return fmt.Sprintf("%s error at block %d, %s", e.Kind, e.Order, e.Err.Error())
+}
+
+func (e *SyncError) Unwrap() error {
+	return e.Err
+}
+
+// Errors returns the failures of the sync loop. The errors are dropped while the
+// channel is full, the sync loop does not wait for it. It is closed once the
+// synchronizer stopped.
+func (s *Synchronizer) Errors() <-chan *SyncError {
+	return s.errs
+}
+
+// report sends err to the channel of Errors
+func (s *Synchronizer) report(err *SyncError) {
+	if s.ctx.Err() != nil {
+		return
+	}
+	err.Time = time.Now()
+	if err.Warning {
+		log.Warnf("%s", err.Error())
+	}
+	select {
+	case s.errs <- err:
+	default:
+		log.Debugf("error channel is full, dropped %s", err.Error())
+	}
+}
+
+// watchStuck reports the sync loop once it processed no block for StuckTimeout,
+// apart from waiting for the confirmations of the next block
+func (s *Synchronizer) watchStuck() {
+	interval := s.opt.StuckTimeout / 10
+	if interval <= 0 {
+		interval = s.opt.StuckTimeout
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	reported := false
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+		s.mutex.RLock()
+		since := time.Since(s.status.progressAt)
+		order, waiting := s.status.current, s.status.waiting
+		s.mutex.RUnlock()
+
+		if since < s.opt.StuckTimeout || waiting {
+			reported = false
+			continue
+		}
+		if !reported {
+			s.report(&SyncError{
+				Kind:  ErrorStuck,
+				Order: order,
+				Err:   fmt.Errorf("no block processed for %s", since.Round(time.Second)),
+			})
+			reported = true
+		}
+	}
+}
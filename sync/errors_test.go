@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"errors"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+	"time"
+)
+
+func receiveError(t *testing.T, errs <-chan *SyncError, kind SyncErrorKind) *SyncError {
+	t.Helper()
+	var err *SyncError
+	receive(t, errs, func(v interface{}) bool {
+		err = v.(*SyncError)
+		return err.Kind == kind
+	})
+	return err
+}
+
+func TestSynchronizer_Errors(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(3)
+	node.InjectError("getBlockByOrder", 1, &rpc.Error{Code: -32603, Message: "internal error"})
+	node.InjectError("isBlue", -1, &rpc.Error{Code: -32603, Message: "internal error"})
+
+	opt := newTestOptions(node)
+	opt.StuckTimeout = 50 * time.Millisecond
+	synchronizer := NewSynchronizer(opt)
+	if _, err := synchronizer.Start(&HistoryOrder{Confirmations: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := receiveError(t, synchronizer.Errors(), ErrorFetch)
+	var rpcErr *rpc.Error
+	if err.Order != 0 || !errors.As(err, &rpcErr) || err.Warning {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	err = receiveError(t, synchronizer.Errors(), ErrorColor)
+	if err.Order != 0 || err.Hash != node.Block(0).Hash {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	err = receiveError(t, synchronizer.Errors(), ErrorStuck)
+	if err.Order != 0 {
+		t.Fatalf("expected to be stuck at block 0, got %+v", err)
+	}
+
+	synchronizer.Stop()
+	for range synchronizer.Errors() {
+	}
+}
+
+func TestSynchronizer_NegativeStuckTimeout(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(2)
+
+	opt := newTestOptions(node)
+	opt.StuckTimeout = -time.Second
+	synchronizer := NewSynchronizer(opt)
+	if opt.StuckTimeout != defaultStuckTimeout {
+		t.Fatalf("expected the default stuck timeout, got %s", opt.StuckTimeout)
+	}
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	receiveTxs(t, txChan, 1)
+}
@@ -0,0 +1,121 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/bCoder778/log"
+	"time"
+)
+
+// BlockEvent is sent for every processed block, including the blocks without
+// transactions and the blocks whose transactions are invalid. When Rollback is
+// set the block is rolled back instead and Txs are the transactions to undo.
+// Rollbacks come from the last block backwards, after the events of the blocks
+// they undo, and the blocks are synchronized again afterwards.
+type BlockEvent struct {
+	Order     uint64
+	Hash      string
+	Height    uint64
+	Timestamp time.Time
+	Blue      bool
+	Txsvalid  bool
+	Txs       []rpc.Transaction
+
+	Rollback bool
+	Reason   RollbackReason
+}
+
+// ErrReorgWindow is returned by Start when Options.ReorgWindow is set, the rollbacks
+// can only be delivered in order with the transactions by the events of StartEvents
+var ErrReorgWindow = errors.New("the reorg window needs StartEvents to deliver its rollbacks")
+
+// StartEvents starts syncing like Start and returns the block events,
+// the channel is closed once the synchronizer stopped
+func (s *Synchronizer) StartEvents(info *HistoryOrder) (<-chan *BlockEvent, error) {
+	info, err := s.loadCheckpoint(info)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.opt.RpcAddrs) != 0 {
+		// no node is read from before it was verified, once the node of the
+		// handshake is known the others are checked to be on its chain too
+		s.rpcClient.CheckNodes(s.ctx)
+	}
+	nodeInfo, err := s.handshake()
+	if err != nil {
+		return nil, err
+	}
+	if len(s.opt.RpcAddrs) != 0 {
+		s.rpcClient.CheckNodes(s.ctx)
+	}
+	s.setThreshold(nodeInfo, info.Confirmations)
+	s.setMainOrder(nodeInfo.GraphState.Mainorder)
+	replaced := false
+	if err := s.verifyHistory(info); err != nil {
+		// with the reorg window the block is rolled back instead
+		if s.reorg == nil || !errors.Is(err, ErrChainMismatch) {
+			return nil, err
+		}
+		log.Warnf("%s, roll back", err.Error())
+		replaced = true
+	}
+
+	if len(s.opt.RpcAddrs) != 0 {
+		s.goWait(func() {
+			s.rpcClient.RunHealthCheck(s.ctx, s.opt.HealthCheckInterval)
+		})
+	}
+	if err := s.startSync(info, replaced); err != nil {
+		return nil, fmt.Errorf("failed to load recent blocks, %s", err.Error())
+	}
+	if s.notifier != nil {
+		s.goWait(func() {
+			s.notifier.Run(s.ctx)
+		})
+	}
+	s.goWait(s.watchMainOrder)
+	return s.events, nil
+}
+
+// forwardTxs feeds the channel of Start from the block events and closes it,
+// there are no rollbacks without the reorg window
+func (s *Synchronizer) forwardTxs(events <-chan *BlockEvent) {
+	defer close(s.txChannel)
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if len(ev.Txs) != 0 {
+				select {
+				case <-s.ctx.Done():
+					return
+				case s.txChannel <- ev.Txs:
+				}
+			}
+			s.sent(ev.Order, ev.Hash)
+		}
+	}
+}
+
+func (s *Synchronizer) sent(order uint64, hash string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sentOrder, s.sentHash = order, hash
+}
+
+// emit sends ev, false if the synchronizer was stopped meanwhile
+func (s *Synchronizer) emit(ev *BlockEvent) bool {
+	select {
+	case <-s.ctx.Done():
+		return false
+	case s.events <- ev:
+		return true
+	}
+}
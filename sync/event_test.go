@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+)
+
+func receiveEvents(t *testing.T, events <-chan *BlockEvent, count int) []*BlockEvent {
+	t.Helper()
+	evs := []*BlockEvent{}
+	receive(t, events, func(v interface{}) bool {
+		evs = append(evs, v.(*BlockEvent))
+		return len(evs) == count
+	})
+	return evs
+}
+
+func TestSynchronizer_StartEvents(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)})
+	blk := node.MineBlock(deposit)
+	invalid := node.MineBlock()
+	node.Invalidate(invalid.Hash)
+	red := node.MineBlock()
+	node.SetBlue(red.Hash, false)
+	node.Mine(2)
+
+	opt := newTestOptions(node)
+	opt.ReorgWindow = 5
+	synchronizer := NewSynchronizer(opt)
+	events, err := synchronizer.StartEvents(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+
+	evs := receiveEvents(t, events, 4)
+	for i, ev := range evs {
+		if ev.Order != uint64(i) || ev.Hash != node.Block(uint64(i)).Hash || ev.Rollback {
+			t.Fatalf("unexpected event %d %+v", i, ev)
+		}
+	}
+	if len(evs[blk.Order].Txs) != 2 || !evs[blk.Order].Blue {
+		t.Fatalf("unexpected deposit block %+v", evs[blk.Order])
+	}
+	if evs[invalid.Order].Txsvalid || len(evs[invalid.Order].Txs) != 0 {
+		t.Fatalf("unexpected invalid block %+v", evs[invalid.Order])
+	}
+	if evs[red.Order].Blue || len(evs[red.Order].Txs) != 0 {
+		t.Fatalf("unexpected red block %+v", evs[red.Order])
+	}
+
+	node.Invalidate(blk.Hash)
+	evs = receiveEvents(t, events, 3)
+	if !evs[2].Rollback || evs[2].Order != blk.Order || evs[2].Reason != RollbackTxsValid {
+		t.Fatalf("unexpected rollback %+v", evs[2])
+	}
+}
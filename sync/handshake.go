@@ -0,0 +1,154 @@
+package sync
+
+import (
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"strconv"
+	"strings"
+)
+
+// Handshake is what the synchronizer learned about the node on start
+type Handshake struct {
+	Network         string `json:"network"`
+	Version         int    `json:"version"`
+	BuildVersion    string `json:"buildversion"`
+	ProtocolVersion int32  `json:"protocolversion"`
+	GenesisHash     string `json:"genesis"`
+}
+
+// handshake reads the network, version and genesis of the node
+// and refuses a node which does not match the options
+func (s *Synchronizer) handshake() (*rpc.NodeInfo, error) {
+	nodeInfo, err := s.rpcClient.GetNodeInfoContext(s.ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node info, %s", err.Error())
+	}
+	genesis, err := s.rpcClient.GetBlockHashContext(s.ctx, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get genesis block, %s", err.Error())
+	}
+	node := newHandshake(nodeInfo, genesis)
+	if err := s.checkNode(node); err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	s.node = node
+	s.mutex.Unlock()
+	return nodeInfo, nil
+}
+
+func newHandshake(nodeInfo *rpc.NodeInfo, genesis string) *Handshake {
+	return &Handshake{
+		Network:         nodeInfo.Network,
+		Version:         nodeInfo.Version,
+		BuildVersion:    nodeInfo.BuildVersion,
+		ProtocolVersion: nodeInfo.ProtocolVersion,
+		GenesisHash:     genesis,
+	}
+}
+
+// verifyNode checks every node of the pool like the node of the handshake,
+// see rpc.RpcConfig.VerifyNode
+func (s *Synchronizer) verifyNode(nodeInfo *rpc.NodeInfo, genesis string) error {
+	return s.checkNode(newHandshake(nodeInfo, genesis))
+}
+
+// checkNode refuses a node which does not match the options
+// or is on another chain than the node of the handshake
+func (s *Synchronizer) checkNode(node *Handshake) error {
+	if s.opt.Network != "" {
+		params, err := rpc.NetParams(s.opt.Network)
+		if err != nil {
+			return err
+		}
+		if node.Network != s.opt.Network {
+			return fmt.Errorf("node is on %s, expected %s", node.Network, s.opt.Network)
+		}
+		if params.GenesisHash != nil && params.GenesisHash.String() != node.GenesisHash {
+			return fmt.Errorf("node genesis %s does not match %s genesis %s", node.GenesisHash, s.opt.Network, params.GenesisHash.String())
+		}
+	}
+	if s.opt.MinNodeVersion != "" && compareVersion(node.BuildVersion, s.opt.MinNodeVersion) < 0 {
+		return fmt.Errorf("node version %s is older than %s", node.BuildVersion, s.opt.MinNodeVersion)
+	}
+	s.mutex.RLock()
+	first := s.node
+	s.mutex.RUnlock()
+	if first != nil && (node.Network != first.Network || node.GenesisHash != first.GenesisHash) {
+		return fmt.Errorf("node is on %s with genesis %s, synchronizing %s with genesis %s",
+			node.Network, node.GenesisHash, first.Network, first.GenesisHash)
+	}
+	return nil
+}
+
+// Node returns the result of the handshake, nil before Start
+func (s *Synchronizer) Node() *Handshake {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.node
+}
+
+// CheckAddress returns an error if address does not belong to the network of the options
+func (s *Synchronizer) CheckAddress(address string) error {
+	return CheckAddress(s.opt.Network, address)
+}
+
+// CheckAddress returns an error if address does not belong to network,
+// any address passes when network is empty
+func CheckAddress(network string, address string) error {
+	if network == "" {
+		return nil
+	}
+	params, err := rpc.NetParams(network)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(address, params.NetworkAddressPrefix) {
+		return fmt.Errorf("address %s is not a %s address", address, network)
+	}
+	return nil
+}
+
+// compareVersion compares the numeric parts of two versions like "0.10.6",
+// anything after the numbers such as "+dev" is ignored
+func compareVersion(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+func versionParts(version string) []int {
+	version = strings.TrimPrefix(version, "v")
+	parts := []int{}
+	for _, part := range strings.Split(version, ".") {
+		end := 0
+		for end < len(part) && part[end] >= '0' && part[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(part[:end])
+		if err != nil {
+			break
+		}
+		parts = append(parts, n)
+		if end != len(part) {
+			break
+		}
+	}
+	return parts
+}
@@ -0,0 +1,102 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"strings"
+	"testing"
+)
+
+func TestSynchronizer_Handshake(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+
+	synchronizer := newTestSynchronizer(node)
+	if _, err := synchronizer.Start(&HistoryOrder{Confirmations: 1}); err != nil {
+		t.Fatal(err)
+	}
+	synchronizer.Stop()
+	info := synchronizer.Node()
+	if info == nil || info.Network != "testnet" || info.GenesisHash != node.Block(0).Hash {
+		t.Fatalf("unexpected handshake %+v", info)
+	}
+
+	opt := newTestOptions(node)
+	opt.Network = "mainnet"
+	if _, err := NewSynchronizer(opt).Start(&HistoryOrder{Confirmations: 1}); err == nil || !strings.Contains(err.Error(), "testnet") {
+		t.Fatalf("expected a network mismatch, got %v", err)
+	}
+	opt = newTestOptions(node)
+	opt.Network = "testnet"
+	if _, err := NewSynchronizer(opt).Start(&HistoryOrder{Confirmations: 1}); err == nil || !strings.Contains(err.Error(), "genesis") {
+		t.Fatalf("expected a genesis mismatch, got %v", err)
+	}
+	opt = newTestOptions(node)
+	opt.MinNodeVersion = "0.11"
+	if _, err := NewSynchronizer(opt).Start(&HistoryOrder{Confirmations: 1}); err == nil || !strings.Contains(err.Error(), "older") {
+		t.Fatalf("expected an old version, got %v", err)
+	}
+}
+
+func TestSynchronizer_HandshakePool(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	other := rpctest.NewNode()
+	defer other.Close()
+	other.Network = "mainnet"
+	node.Mine(3)
+
+	opt := newTestOptions(node)
+	opt.RpcAddrs = []string{other.Addr()}
+	synchronizer := NewSynchronizer(opt)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	receiveTxs(t, txChan, 2)
+
+	for _, status := range synchronizer.Nodes() {
+		if status.Address == other.Addr() && (status.Verified || !strings.Contains(status.LastError, "mainnet")) {
+			t.Fatalf("expected the node on mainnet to be refused, got %+v", status)
+		}
+	}
+	if _, err := synchronizer.SendTx("00"); err != nil {
+		t.Fatal(err)
+	}
+	node.Close()
+	if _, err := synchronizer.GetTx(node.Block(1).Transactions[0].Txid); err == nil {
+		t.Fatal("expected no fail over to the node on mainnet")
+	}
+	if calls := other.Calls("sendRawTransaction") + other.Calls("getBlockByOrder") + other.Calls("getRawTransaction"); calls != 0 {
+		t.Fatalf("the node on mainnet was used %d times", calls)
+	}
+}
+
+func TestCheckAddress(t *testing.T) {
+	if err := CheckAddress("testnet", "TnU8gXq9xHFrfchwk2bjyGHR2HMswANsVU5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckAddress("mainnet", "TnU8gXq9xHFrfchwk2bjyGHR2HMswANsVU5"); err == nil {
+		t.Fatal("expected a testnet address to be refused on mainnet")
+	}
+	if err := CheckAddress("", "anything"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.10.6", "0.10.6", 0},
+		{"0.10.6+dev", "0.10.5", 1},
+		{"v0.9.12", "0.10", -1},
+		{"0.10", "0.10.0", 0},
+	}
+	for _, test := range tests {
+		if got := compareVersion(test.a, test.b); got != test.want {
+			t.Errorf("compareVersion(%s, %s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/bCoder778/log"
+	"time"
+)
+
+// MempoolState is the state of a watched transaction
+type MempoolState string
+
+const (
+	// the transaction is in the mempool
+	MempoolPending MempoolState = "pending"
+	// the transaction left the mempool for a block, it is sent again
+	// by the synchronizer once the block is confirmed
+	MempoolConfirmed MempoolState = "confirmed"
+	// the transaction left the mempool without a block
+	MempoolDropped MempoolState = "dropped"
+)
+
+// MempoolEvent is sent when a transaction paying to a watched address
+// enters the mempool and when it leaves it
+type MempoolEvent struct {
+	State MempoolState
+	Tx    *rpc.Transaction
+	// the watched addresses the transaction pays to
+	Addresses []string
+}
+
+type mempoolWatcher struct {
+	s      *Synchronizer
+	watch  func(address string) bool
+	events chan *MempoolEvent
+	// watched transactions in the mempool
+	pending map[string]*MempoolEvent
+	// the other transactions in the mempool, not fetched again
+	ignored map[string]bool
+}
+
+// WatchMempool polls the mempool every PollInterval and sends an event for every
+// transaction paying to an address watch returns true for. The channel is closed
+// once the synchronizer stopped.
+func (s *Synchronizer) WatchMempool(watch func(address string) bool) <-chan *MempoolEvent {
+	w := &mempoolWatcher{
+		s:       s,
+		watch:   watch,
+		events:  make(chan *MempoolEvent, s.opt.TxChLen),
+		pending: map[string]*MempoolEvent{},
+		ignored: map[string]bool{},
+	}
+	s.goWait(w.run)
+	return w.events
+}
+
+func (w *mempoolWatcher) run() {
+	defer close(w.events)
+
+	// the mempool is shown to users, it must not slow down the sync loop
+	ctx := rpc.WithPriority(w.s.ctx, rpc.PriorityBackground)
+	t := time.NewTicker(w.s.opt.PollInterval)
+	defer t.Stop()
+
+	for {
+		if err := w.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Warnf("failed to poll mempool, %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (w *mempoolWatcher) poll(ctx context.Context) error {
+	txids, err := w.s.rpcClient.GetMemoryPoolContext(ctx)
+	if err != nil {
+		return err
+	}
+	inPool := make(map[string]bool, len(txids))
+	for _, txid := range txids {
+		inPool[txid] = true
+		if w.pending[txid] != nil || w.ignored[txid] {
+			continue
+		}
+		tx, err := w.s.rpcClient.GetTransactionContext(ctx, txid)
+		if errors.Is(err, rpc.ErrTxNotFound) {
+			// it was mined or dropped meanwhile
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		addresses := w.match(tx)
+		if len(addresses) == 0 {
+			w.ignored[txid] = true
+			continue
+		}
+		ev := &MempoolEvent{State: MempoolPending, Tx: tx, Addresses: addresses}
+		if !w.send(ev) {
+			return nil
+		}
+		w.pending[txid] = ev
+	}
+	for txid := range w.ignored {
+		if !inPool[txid] {
+			delete(w.ignored, txid)
+		}
+	}
+
+	for txid, pending := range w.pending {
+		if inPool[txid] {
+			continue
+		}
+		ev := &MempoolEvent{State: MempoolDropped, Tx: pending.Tx, Addresses: pending.Addresses}
+		tx, err := w.s.rpcClient.GetTransactionContext(ctx, txid)
+		switch {
+		case errors.Is(err, rpc.ErrTxNotFound):
+		case err != nil:
+			return err
+		case tx.Blockhash == "":
+			// still in the mempool of the node
+			continue
+		default:
+			ev.State, ev.Tx = MempoolConfirmed, tx
+		}
+		if !w.send(ev) {
+			return nil
+		}
+		delete(w.pending, txid)
+	}
+	return nil
+}
+
+// match returns the watched addresses tx pays to
+func (w *mempoolWatcher) match(tx *rpc.Transaction) []string {
+	var addresses []string
+	for _, vout := range tx.Vout {
+		for _, address := range vout.ScriptPubKey.Addresses {
+			if w.watch(address) {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+func (w *mempoolWatcher) send(ev *MempoolEvent) bool {
+	select {
+	case <-w.s.ctx.Done():
+		return false
+	case w.events <- ev:
+		return true
+	}
+}
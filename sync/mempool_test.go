@@ -0,0 +1,57 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+)
+
+func receiveMempoolEvent(t *testing.T, events <-chan *MempoolEvent) *MempoolEvent {
+	t.Helper()
+	var ev *MempoolEvent
+	receive(t, events, func(v interface{}) bool {
+		ev = v.(*MempoolEvent)
+		return true
+	})
+	return ev
+}
+
+func TestSynchronizer_WatchMempool(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	synchronizer := newTestSynchronizer(node)
+	defer synchronizer.Stop()
+	events := synchronizer.WatchMempool(func(address string) bool {
+		return address == "TmWatched"
+	})
+
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmWatched", 100)})
+	dropped := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmWatched", 200)})
+	node.AddToMempool(rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmOther", 300)}))
+	node.AddToMempool(deposit)
+	node.AddToMempool(dropped)
+
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		ev := receiveMempoolEvent(t, events)
+		if ev.State != MempoolPending || len(ev.Addresses) != 1 || ev.Addresses[0] != "TmWatched" {
+			t.Fatalf("unexpected event %+v", ev)
+		}
+		seen[ev.Tx.Txid] = true
+	}
+	if !seen[deposit.Txid] || !seen[dropped.Txid] {
+		t.Fatalf("expected the watched transactions to be pending, got %v", seen)
+	}
+
+	blk := node.MineBlock(deposit)
+	ev := receiveMempoolEvent(t, events)
+	if ev.State != MempoolConfirmed || ev.Tx.Txid != deposit.Txid || ev.Tx.Blockhash != blk.Hash {
+		t.Fatalf("expected the deposit to be confirmed, got %+v", ev)
+	}
+
+	node.DropFromMempool(dropped.Txid)
+	ev = receiveMempoolEvent(t, events)
+	if ev.State != MempoolDropped || ev.Tx.Txid != dropped.Txid {
+		t.Fatalf("expected the transaction to be dropped, got %+v", ev)
+	}
+}
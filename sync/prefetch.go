@@ -0,0 +1,86 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+)
+
+// fetchResult is a block together with its color, the color
+// is only asked for once the transactions of the block are confirmed
+type fetchResult struct {
+	block    *rpc.Block
+	isBlue   bool
+	colorErr error
+	err      error
+}
+
+// prefetcher keeps the blocks after the cursor in flight while they are known
+// to be confirmed, the results are taken in order. It is only used by the sync loop.
+type prefetcher struct {
+	workers int
+	// next order to fetch and the last order known to be confirmed
+	next    uint64
+	last    uint64
+	pending map[uint64]chan *fetchResult
+}
+
+func newPrefetcher(workers int) *prefetcher {
+	return &prefetcher{workers: workers, pending: map[uint64]chan *fetchResult{}}
+}
+
+// fetch returns the block at order, fetched ahead if possible
+func (s *Synchronizer) fetch(order uint64) *fetchResult {
+	p := s.prefetch
+	if p == nil {
+		return s.fetchBlock(order)
+	}
+	ch, ok := p.pending[order]
+	if !ok {
+		// the cursor moved back or the blocks ahead were dropped
+		p.pending = map[uint64]chan *fetchResult{}
+		ch = s.startFetch(order)
+		p.next = order + 1
+	}
+	delete(p.pending, order)
+
+	var res *fetchResult
+	select {
+	case <-s.ctx.Done():
+		return &fetchResult{err: s.ctx.Err()}
+	case res = <-ch:
+	}
+	if res.err != nil || !s.isTxConfirmed(res.block) {
+		// the blocks after it are failing or unconfirmed as well
+		p.pending = map[uint64]chan *fetchResult{}
+		return res
+	}
+	// the blocks at least threshold orders below the tip are confirmed
+	tip := res.block.Order + uint64(res.block.Confirmations)
+	if last := tip - uint64(s.threshold.transactionThreshold) - 1; last > p.last {
+		p.last = last
+	}
+	for len(p.pending) < p.workers && p.next <= p.last {
+		p.pending[p.next] = s.startFetch(p.next)
+		p.next++
+	}
+	return res
+}
+
+func (s *Synchronizer) startFetch(order uint64) chan *fetchResult {
+	ch := make(chan *fetchResult, 1)
+	go func() {
+		ch <- s.fetchBlock(order)
+	}()
+	return ch
+}
+
+func (s *Synchronizer) fetchBlock(order uint64) *fetchResult {
+	block, err := s.getBlock(order)
+	if err != nil {
+		return &fetchResult{err: err}
+	}
+	res := &fetchResult{block: block}
+	if s.isTxConfirmed(block) {
+		res.isBlue, res.colorErr = s.IsCoinBaseUsable(block)
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+	"time"
+)
+
+func TestSynchronizer_PrefetchInOrder(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Latency = time.Millisecond
+	for i := 0; i < 30; i++ {
+		node.MineBlock(rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)}))
+	}
+	node.Mine(2)
+	node.InjectError("getBlockByOrder", 2, &rpc.Error{Code: -32603, Message: "internal error"})
+
+	opt := newTestOptions(node)
+	opt.PrefetchWorkers = 8
+	synchronizer := NewSynchronizer(opt)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+
+	// coinbase and deposit of every confirmed block
+	txs := receiveTxs(t, txChan, 2*30-1)
+	for i := 1; i < len(txs); i++ {
+		if txs[i].BlockOrder < txs[i-1].BlockOrder {
+			t.Fatalf("block %d was sent after block %d", txs[i].BlockOrder, txs[i-1].BlockOrder)
+		}
+	}
+	select {
+	case txs := <-txChan:
+		t.Fatalf("unconfirmed transactions were sent %+v", txs)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	node.Mine(1)
+	txs = receiveTxs(t, txChan, 2)
+	if txs[0].BlockOrder != 30 {
+		t.Fatalf("expected block 30, got %d", txs[0].BlockOrder)
+	}
+}
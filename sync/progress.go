@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/bCoder778/log"
+)
+
+// TxState is the state of a transaction sent by ConfirmationProgress
+type TxState string
+
+const (
+	// the block of the transaction is not confirmed yet
+	TxConfirming TxState = "confirming"
+	// the transaction is confirmed, it is sent by Start at the same time
+	TxFinal TxState = "final"
+	// the transaction left its block, its block became invalid, it is the coinbase
+	// of a red block or it was rolled back. It may be sent again in another block.
+	TxOrphaned TxState = "orphaned"
+)
+
+// TxProgress is the confirmation progress of a transaction
+type TxProgress struct {
+	State         TxState
+	Tx            *rpc.Transaction
+	BlockHash     string
+	Order         uint64
+	Confirmations uint32
+	// the confirmations the transaction needs to be final
+	Threshold uint32
+}
+
+// trackedTx is a transaction sent as confirming
+type trackedTx struct {
+	hash          string
+	order         uint64
+	confirmations uint32
+	tx            rpc.Transaction
+}
+
+// ConfirmationProgress returns the transactions of the unconfirmed blocks, sent again
+// whenever their confirmations change until they are final or orphaned. Only sent
+// when Options.ConfirmationProgress is set, the channel has to be read from then.
+// It is closed once the synchronizer stopped.
+func (s *Synchronizer) ConfirmationProgress() <-chan *TxProgress {
+	return s.progress
+}
+
+// trackUnconfirmed sends the transactions of the blocks from the first unconfirmed
+// block to the tip whose confirmations changed, and the ones which left them
+func (s *Synchronizer) trackUnconfirmed(first *rpc.Block) {
+	if s.tracked == nil {
+		return
+	}
+	blocks, err := s.rpcClient.GetBlocksByOrderRangeContext(s.ctx, first.Order, first.Order+uint64(first.Confirmations))
+	if err != nil {
+		log.Debugf("failed to get unconfirmed blocks, %s", err.Error())
+		return
+	}
+	seen := map[string]bool{}
+	for _, block := range blocks {
+		if !block.Txsvalid {
+			continue
+		}
+		for _, tx := range block.Transactions {
+			if tx.Duplicate {
+				continue
+			}
+			if s.opt.Watch != nil {
+				// the same outputs as the transaction sent once it is final
+				filtered, ok := s.opt.Watch.peek(&tx)
+				if !ok {
+					continue
+				}
+				tx = *filtered
+			}
+			seen[tx.Txid] = true
+			t := s.tracked[tx.Txid]
+			if t != nil && t.hash == block.Hash && t.confirmations == block.Confirmations {
+				continue
+			}
+			tx.IsCoinBase = isCoinBase(&tx)
+			tx.BlockOrder = block.Order
+			tx.BlockHeight = block.Height
+			t = &trackedTx{hash: block.Hash, order: block.Order, confirmations: block.Confirmations, tx: tx}
+			if !s.sendProgress(TxConfirming, t) {
+				return
+			}
+			s.tracked[tx.Txid] = t
+		}
+	}
+	for txid, t := range s.tracked {
+		if seen[txid] {
+			continue
+		}
+		if !s.sendProgress(TxOrphaned, t) {
+			return
+		}
+		delete(s.tracked, txid)
+	}
+}
+
+// finalize sends the transactions sent by Start for block as final, the other
+// transactions sent as confirming in the block are orphaned
+func (s *Synchronizer) finalize(block *rpc.Block, txs []rpc.Transaction) {
+	if s.tracked == nil {
+		return
+	}
+	final := map[string]bool{}
+	for _, tx := range txs {
+		final[tx.Txid] = true
+		t := &trackedTx{hash: block.Hash, order: block.Order, confirmations: block.Confirmations, tx: tx}
+		if !s.sendProgress(TxFinal, t) {
+			return
+		}
+		delete(s.tracked, tx.Txid)
+	}
+	for txid, t := range s.tracked {
+		if t.order != block.Order || final[txid] {
+			continue
+		}
+		if !s.sendProgress(TxOrphaned, t) {
+			return
+		}
+		delete(s.tracked, txid)
+	}
+}
+
+// orphan sends the transactions of a rolled back block as orphaned
+func (s *Synchronizer) orphan(block *sentBlock) {
+	if s.tracked == nil {
+		return
+	}
+	for _, tx := range block.txs {
+		if !s.sendProgress(TxOrphaned, &trackedTx{hash: block.hash, order: block.order, tx: tx}) {
+			return
+		}
+	}
+}
+
+func (s *Synchronizer) sendProgress(state TxState, t *trackedTx) bool {
+	tx := t.tx
+	p := &TxProgress{
+		State:         state,
+		Tx:            &tx,
+		BlockHash:     t.hash,
+		Order:         t.order,
+		Confirmations: t.confirmations,
+		Threshold:     s.threshold.transactionThreshold + 1,
+	}
+	select {
+	case <-s.ctx.Done():
+		return false
+	case s.progress <- p:
+		return true
+	}
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+)
+
+func receiveProgress(t *testing.T, progress <-chan *TxProgress, txid string, state TxState) *TxProgress {
+	t.Helper()
+	var p *TxProgress
+	receive(t, progress, func(v interface{}) bool {
+		p = v.(*TxProgress)
+		return p.Tx.Txid == txid && p.State == state
+	})
+	return p
+}
+
+func TestSynchronizer_ConfirmationProgress(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(1)
+
+	opt := newTestOptions(node)
+	opt.ConfirmationProgress = true
+	synchronizer := NewSynchronizer(opt)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	progress := synchronizer.ConfirmationProgress()
+
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)})
+	blk := node.MineBlock(deposit)
+	p := receiveProgress(t, progress, deposit.Txid, TxConfirming)
+	if p.Confirmations != 0 || p.Threshold != 3 || p.BlockHash != blk.Hash || p.Order != blk.Order {
+		t.Fatalf("unexpected progress %+v", p)
+	}
+	node.Mine(1)
+	if p := receiveProgress(t, progress, deposit.Txid, TxConfirming); p.Confirmations != 1 {
+		t.Fatalf("expected 1 confirmation, got %+v", p)
+	}
+
+	orphan := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 200)})
+	orphanBlk := node.MineBlock(orphan)
+	receiveProgress(t, progress, orphan.Txid, TxConfirming)
+	node.ReplaceBlock(orphanBlk.Order)
+	receiveProgress(t, progress, orphan.Txid, TxOrphaned)
+
+	node.Mine(1)
+	p = receiveProgress(t, progress, deposit.Txid, TxFinal)
+	if p.Confirmations != 3 || p.Tx.BlockOrder != blk.Order {
+		t.Fatalf("unexpected progress %+v", p)
+	}
+	// the confirmed stream sends the same transaction
+	txs := receiveTxs(t, txChan, 4)
+	if txs[3].Txid != deposit.Txid {
+		t.Fatalf("expected the deposit, got %+v", txs[3])
+	}
+}
@@ -0,0 +1,206 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/bCoder778/log"
+)
+
+// RollbackReason tells why the transactions of a block are rolled back
+type RollbackReason string
+
+const (
+	// the node has another block at the order
+	RollbackOrder RollbackReason = "order"
+	// the transactions of the block became invalid or valid
+	RollbackTxsValid RollbackReason = "txsvalid"
+	// the block turned red or blue, which decides over its coinbase
+	RollbackColor RollbackReason = "color"
+	// a block before it changed, it is synchronized again
+	RollbackRewind RollbackReason = "rewind"
+)
+
+// sentBlock is the state of a processed block when its transactions were sent
+type sentBlock struct {
+	order    uint64
+	hash     string
+	txsvalid bool
+	blue     bool
+	txs      []rpc.Transaction
+}
+
+// reorgWindow keeps the recently processed blocks in order
+type reorgWindow struct {
+	size   int
+	blocks []*sentBlock
+}
+
+func (w *reorgWindow) add(block *sentBlock) {
+	w.blocks = append(w.blocks, block)
+	if len(w.blocks) > w.size {
+		w.blocks = w.blocks[len(w.blocks)-w.size:]
+	}
+}
+
+// truncate removes the blocks from order on and returns them
+func (w *reorgWindow) truncate(order uint64) []*sentBlock {
+	for i, block := range w.blocks {
+		if block.order >= order {
+			removed := w.blocks[i:]
+			w.blocks = w.blocks[:i:i]
+			return removed
+		}
+	}
+	return nil
+}
+
+// preloadReorgWindow fills the reorg window with the blocks before start,
+// as if their transactions were just sent
+func (s *Synchronizer) preloadReorgWindow(start uint64) error {
+	if s.reorg == nil || start == 0 {
+		return nil
+	}
+	from := uint64(0)
+	if start > uint64(s.reorg.size) {
+		from = start - uint64(s.reorg.size)
+	}
+	blocks, colors, err := s.getRecentBlocks(s.ctx, from, start-1, true)
+	if err != nil {
+		return err
+	}
+	for i, block := range blocks {
+		blue := colors[i] == 1
+		sent := &sentBlock{order: block.Order, hash: block.Hash, txsvalid: block.Txsvalid, blue: blue}
+		if block.Txsvalid {
+			sent.txs = s.filterTxs(s.getConfirmedTx(block, blue))
+		}
+		s.reorg.add(sent)
+		s.mutex.Lock()
+		s.hashes.add(block.Order, block.Hash)
+		s.mutex.Unlock()
+	}
+	return nil
+}
+
+// loadReplacedBlock puts the last block of a previous sync, which the node replaced
+// while the synchronizer was down, at the end of the reorg window. The sync loop
+// finds the other block at its order, rolls it back and continues from there.
+func (s *Synchronizer) loadReplacedBlock(info *HistoryOrder) error {
+	sent := &sentBlock{order: info.LastTxBlockOrder, hash: info.LastBlockHash}
+	block, err := s.rpcClient.GetBlockContext(s.ctx, info.LastBlockHash)
+	var rpcErr *rpc.Error
+	switch {
+	case errors.As(err, &rpcErr) && rpcErr.Code == rpc.ErrCodeInvalidAddressOrKey:
+		log.Warnf("block %s is unknown to the node, it is rolled back without its transactions", info.LastBlockHash)
+	case err != nil:
+		return err
+	default:
+		color, err := s.rpcClient.IsBlueContext(s.ctx, block.Hash)
+		if err != nil {
+			return err
+		}
+		// the transactions as they were sent at the order of the previous sync
+		block.Order = info.LastTxBlockOrder
+		sent.txsvalid, sent.blue = block.Txsvalid, color == 1
+		if block.Txsvalid {
+			sent.txs = s.filterTxs(s.getConfirmedTx(block, sent.blue))
+		}
+	}
+	s.reorg.add(sent)
+	return nil
+}
+
+// getRecentBlocks fetches the blocks in [from, to] with their colors,
+// the blocks come without their transactions unless withTxs is set
+func (s *Synchronizer) getRecentBlocks(ctx context.Context, from, to uint64, withTxs bool) ([]*rpc.Block, []int, error) {
+	fetch := s.rpcClient.GetBlocksByOrderRangeNoTxContext
+	if withTxs {
+		fetch = s.rpcClient.GetBlocksByOrderRangeContext
+	}
+	blocks, err := fetch(ctx, from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		hashes = append(hashes, block.Hash)
+	}
+	colors, err := s.rpcClient.IsBlueBatchContext(ctx, hashes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return blocks, colors, nil
+}
+
+// recheck fetches the blocks of the reorg window again without their transactions, the
+// ones sent are kept in the window. From the first block whose order, validity or color
+// changed on, the blocks are rolled back and synchronized again.
+func (s *Synchronizer) recheck() {
+	if s.reorg == nil || len(s.reorg.blocks) == 0 {
+		return
+	}
+	sent := s.reorg.blocks
+	blocks, colors, err := s.getRecentBlocks(s.ctx, sent[0].order, sent[len(sent)-1].order, false)
+	if err != nil {
+		s.report(&SyncError{Kind: ErrorRecheck, Order: sent[0].order, Err: err, Warning: true})
+		return
+	}
+	for i, block := range blocks {
+		var reason RollbackReason
+		switch {
+		case block.Hash != sent[i].hash:
+			reason = RollbackOrder
+		case block.Txsvalid != sent[i].txsvalid:
+			reason = RollbackTxsValid
+		case sent[i].txsvalid && (colors[i] == 1) != sent[i].blue:
+			reason = RollbackColor
+		default:
+			continue
+		}
+		log.Infof("block %s at order %d changed (%s), roll back", sent[i].hash, sent[i].order, reason)
+		s.rollback(sent[i].order, reason)
+		return
+	}
+}
+
+// rollback sends the rollbacks of the blocks from order on and synchronizes them again
+func (s *Synchronizer) rollback(order uint64, reason RollbackReason) {
+	removed := s.reorg.truncate(order)
+	for i := len(removed) - 1; i >= 0; i-- {
+		block := removed[i]
+		ev := &BlockEvent{
+			Order:    block.order,
+			Hash:     block.hash,
+			Blue:     block.blue,
+			Txsvalid: block.txsvalid,
+			Txs:      block.txs,
+			Rollback: true,
+			Reason:   RollbackRewind,
+		}
+		if i == 0 {
+			ev.Reason = reason
+		}
+		if !s.emit(ev) {
+			return
+		}
+		s.orphan(block)
+		if s.opt.Watch != nil {
+			for j := range block.txs {
+				s.opt.Watch.revert(&block.txs[len(block.txs)-1-j])
+			}
+		}
+	}
+
+	s.mutex.Lock()
+	s.hashes.truncate(order)
+	s.lastOrder, s.lastHash = 0, ""
+	if order > 0 {
+		s.lastOrder = order - 1
+		s.lastHash, _ = s.hashes.hash(order - 1)
+	}
+	lastOrder, lastHash := s.lastOrder, s.lastHash
+	s.mutex.Unlock()
+	s.rewindCheckpoint(lastOrder, lastHash)
+	s.curTxBlockOrder = order
+}
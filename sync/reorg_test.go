@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"errors"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+)
+
+// receiveRollbacks takes the events until count rollbacks were received and returns the rollbacks
+func receiveRollbacks(t *testing.T, events <-chan *BlockEvent, count int) []*BlockEvent {
+	t.Helper()
+	rbs := []*BlockEvent{}
+	receive(t, events, func(v interface{}) bool {
+		if ev := v.(*BlockEvent); ev.Rollback {
+			rbs = append(rbs, ev)
+		}
+		return len(rbs) == count
+	})
+	return rbs
+}
+
+func startReorgSynchronizer(t *testing.T, node *rpctest.Node) (*Synchronizer, <-chan *BlockEvent) {
+	opt := newTestOptions(node)
+	opt.ReorgWindow = 5
+	synchronizer := NewSynchronizer(opt)
+	events, err := synchronizer.StartEvents(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { synchronizer.Stop() })
+	return synchronizer, events
+}
+
+func TestSynchronizer_RollbackInvalidBlock(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)})
+	blk := node.MineBlock(deposit)
+	node.Mine(2)
+
+	_, events := startReorgSynchronizer(t, node)
+	// the genesis and the deposit block
+	receiveEvents(t, events, 2)
+
+	node.Invalidate(blk.Hash)
+	rbs := receiveRollbacks(t, events, 1)
+	if rbs[0].Order != blk.Order || rbs[0].Reason != RollbackTxsValid || len(rbs[0].Txs) != 2 || rbs[0].Txs[1].Txid != deposit.Txid {
+		t.Fatalf("unexpected rollback %+v", rbs[0])
+	}
+
+	// the block is synchronized again without its transactions
+	evs := receiveEvents(t, events, 1)
+	if evs[0].Order != blk.Order || evs[0].Txsvalid || len(evs[0].Txs) != 0 {
+		t.Fatalf("expected the invalid block %d, got %+v", blk.Order, evs[0])
+	}
+}
+
+func TestSynchronizer_RollbackReplacedBlock(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(4)
+
+	_, events := startReorgSynchronizer(t, node)
+	receiveEvents(t, events, 3)
+
+	replaced := node.ReplaceBlock(1)
+	rbs := receiveRollbacks(t, events, 2)
+	if rbs[0].Order != 2 || rbs[0].Reason != RollbackRewind || rbs[1].Order != 1 || rbs[1].Reason != RollbackOrder {
+		t.Fatalf("unexpected rollbacks %+v %+v", rbs[0], rbs[1])
+	}
+	evs := receiveEvents(t, events, 2)
+	if evs[0].Hash != replaced.Hash || evs[0].Txs[0].Blockhash != replaced.Hash {
+		t.Fatalf("expected the new block, got %+v", evs[0])
+	}
+}
+
+func TestSynchronizer_RollbackLastProcessedBlock(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	// more orders than the parents are checked after
+	node.Mine(defaultParentWindow + 10)
+
+	_, events := startReorgSynchronizer(t, node)
+	receiveEvents(t, events, defaultParentWindow+9)
+	last := uint64(defaultParentWindow + 8)
+
+	// the next block refers to the replacement of the last processed one
+	replaced := node.ReplaceBlock(last)
+	rbs := receiveRollbacks(t, events, 1)
+	if rbs[0].Order != last || rbs[0].Reason != RollbackOrder {
+		t.Fatalf("unexpected rollback %+v", rbs[0])
+	}
+	evs := receiveEvents(t, events, 1)
+	if evs[0].Hash != replaced.Hash {
+		t.Fatalf("expected the new block, got %+v", evs[0])
+	}
+}
+
+func TestSynchronizer_RollbackRedBlock(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	blk := node.MineBlock()
+	node.Mine(2)
+
+	_, events := startReorgSynchronizer(t, node)
+	receiveEvents(t, events, 2)
+
+	node.SetBlue(blk.Hash, false)
+	rbs := receiveRollbacks(t, events, 1)
+	if rbs[0].Order != blk.Order || rbs[0].Reason != RollbackColor || len(rbs[0].Txs) != 1 {
+		t.Fatalf("unexpected rollback %+v", rbs[0])
+	}
+	// the coinbase of a red block is not sent again
+	evs := receiveEvents(t, events, 1)
+	if evs[0].Order != blk.Order || evs[0].Blue || len(evs[0].Txs) != 0 {
+		t.Fatalf("expected the red block without its coinbase, got %+v", evs[0])
+	}
+}
+
+func TestSynchronizer_StartWithReorgWindow(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+
+	opt := newTestOptions(node)
+	opt.ReorgWindow = 5
+	synchronizer := NewSynchronizer(opt)
+	defer synchronizer.Stop()
+	if _, err := synchronizer.Start(&HistoryOrder{}); !errors.Is(err, ErrReorgWindow) {
+		t.Fatalf("expected ErrReorgWindow, got %v", err)
+	}
+}
+
+func TestSynchronizer_ResumeReplacedBlock(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)})
+	node.Mine(1)
+	blk := node.MineBlock(deposit)
+	node.Mine(2)
+
+	synchronizer, events := startReorgSynchronizer(t, node)
+	receiveEvents(t, events, 3)
+	history := synchronizer.Stop()
+	if history.LastTxBlockOrder != blk.Order || history.LastBlockHash != blk.Hash {
+		t.Fatalf("expected to stop at the deposit block, got %+v", history)
+	}
+
+	// the dag is reorganized while the synchronizer is down
+	replaced := node.ReplaceBlock(blk.Order)
+	opt := newTestOptions(node)
+	opt.ReorgWindow = 5
+	synchronizer = NewSynchronizer(opt)
+	history.Confirmations = 1
+	events, err := synchronizer.StartEvents(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	evs := receiveEvents(t, events, 2)
+	if !evs[0].Rollback || evs[0].Order != blk.Order || evs[0].Hash != blk.Hash || evs[0].Reason != RollbackOrder ||
+		len(evs[0].Txs) != 2 || evs[0].Txs[1].Txid != deposit.Txid {
+		t.Fatalf("expected the rollback of the deposit block, got %+v", evs[0])
+	}
+	if evs[1].Rollback || evs[1].Order != blk.Order || evs[1].Hash != replaced.Hash {
+		t.Fatalf("expected the new block, got %+v", evs[1])
+	}
+}
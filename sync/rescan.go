@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/bCoder778/log"
+	sync2 "sync"
+)
+
+const defaultRescanBatch = 50
+
+// RescanProgress is the state of a rescan
+type RescanProgress struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+	// the next block to scan
+	Current uint64 `json:"current"`
+	// the transactions found so far
+	Txs   int    `json:"txs"`
+	Done  bool   `json:"done"`
+	Error string `json:"error,omitempty"`
+}
+
+// Rescan scans the synchronized blocks again for the transactions of some addresses,
+// e.g. of addresses added after their blocks were synchronized
+type Rescan struct {
+	s         *Synchronizer
+	from, to  uint64
+	addresses map[string]bool
+	// outputs paying to the addresses, their spends are sent as well
+	outputs map[string]bool
+	txs     chan []rpc.Transaction
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mutex    sync2.Mutex
+	progress RescanProgress
+	done     chan struct{}
+	err      error
+}
+
+// Rescan starts scanning the blocks from order from to order to for the transactions
+// paying to addresses or spending their outputs. It runs alongside the sync loop,
+// the blocks have to be synchronized already. The transactions of a block are sent at
+// once on the channel of Txs, which is closed when the rescan is done.
+func (s *Synchronizer) Rescan(from, to uint64, addresses []string) (*Rescan, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid block order range [%d, %d]", from, to)
+	}
+	if last := s.GetHistoryOrder().LastTxBlockOrder; to > last {
+		return nil, fmt.Errorf("block %d is not synchronized yet, the last is %d", to, last)
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	r := &Rescan{
+		s:         s,
+		from:      from,
+		to:        to,
+		addresses: map[string]bool{},
+		outputs:   map[string]bool{},
+		txs:       make(chan []rpc.Transaction, s.opt.TxChLen),
+		// rescans must not slow down the sync loop
+		ctx:      rpc.WithPriority(ctx, rpc.PriorityBackground),
+		cancel:   cancel,
+		progress: RescanProgress{From: from, To: to, Current: from},
+		done:     make(chan struct{}),
+	}
+	for _, address := range addresses {
+		r.addresses[address] = true
+	}
+	s.goWait(r.run)
+	return r, nil
+}
+
+// Txs returns the transactions found
+func (r *Rescan) Txs() <-chan []rpc.Transaction {
+	return r.txs
+}
+
+// Progress returns the state of the rescan
+func (r *Rescan) Progress() RescanProgress {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.progress
+}
+
+// Cancel stops the rescan
+func (r *Rescan) Cancel() {
+	r.cancel()
+}
+
+// Wait blocks until the rescan is done and returns why it failed, if it did
+func (r *Rescan) Wait() error {
+	<-r.done
+	return r.err
+}
+
+func (r *Rescan) run() {
+	err := r.scan()
+	if err != nil {
+		log.Warnf("rescan of blocks [%d, %d] stopped, %s", r.from, r.to, err.Error())
+	}
+	r.mutex.Lock()
+	r.progress.Done = true
+	if err != nil {
+		r.progress.Error = err.Error()
+	}
+	r.mutex.Unlock()
+
+	r.err = err
+	r.cancel()
+	close(r.txs)
+	close(r.done)
+}
+
+func (r *Rescan) scan() error {
+	for order := r.from; order <= r.to; {
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		last := order + defaultRescanBatch - 1
+		if last > r.to {
+			last = r.to
+		}
+		blocks, colors, err := r.s.getRecentBlocks(r.ctx, order, last, true)
+		if err != nil {
+			return err
+		}
+		for i, block := range blocks {
+			if err := r.scanBlock(block, colors[i] == 1); err != nil {
+				return err
+			}
+		}
+		order += uint64(len(blocks))
+	}
+	return nil
+}
+
+func (r *Rescan) scanBlock(block *rpc.Block, isBlue bool) error {
+	var found []rpc.Transaction
+	if block.Txsvalid {
+		for _, tx := range r.s.getConfirmedTx(block, isBlue) {
+			if r.match(&tx) {
+				found = append(found, tx)
+			}
+		}
+	}
+	if len(found) != 0 {
+		select {
+		case <-r.ctx.Done():
+			return r.ctx.Err()
+		case r.txs <- found:
+		}
+	}
+	r.mutex.Lock()
+	r.progress.Current = block.Order + 1
+	r.progress.Txs += len(found)
+	r.mutex.Unlock()
+	return nil
+}
+
+// match reports whether tx pays to the addresses or spends an output paying to them
+func (r *Rescan) match(tx *rpc.Transaction) bool {
+	matched := false
+	for _, vin := range tx.Vin {
+		if r.outputs[outKey(vin.Txid, vin.Vout)] {
+			matched = true
+		}
+	}
+	for i, vout := range tx.Vout {
+		for _, address := range vout.ScriptPubKey.Addresses {
+			if r.addresses[address] {
+				r.outputs[outKey(tx.Txid, uint64(i))] = true
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+func outKey(txid string, vout uint64) string {
+	return fmt.Sprintf("%s:%d", txid, vout)
+}
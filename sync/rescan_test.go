@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+	"time"
+)
+
+func TestSynchronizer_Rescan(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmNew", 100)})
+	node.MineBlock(deposit)
+	spend := rpctest.NewTransaction([]rpc.Vin{rpctest.Input(deposit.Txid, 0)}, []rpc.Vout{rpctest.Output("TmOther", 90)})
+	node.MineBlock(spend)
+	node.MineBlock(rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmOther", 100)}))
+	node.Mine(2)
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	receiveTxs(t, txChan, 7)
+	for synchronizer.GetHistoryOrder().LastTxBlockOrder < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := synchronizer.Rescan(0, 100, []string{"TmNew"}); err == nil {
+		t.Fatal("expected an error for blocks which were not synchronized")
+	}
+	rescan, err := synchronizer.Rescan(0, 3, []string{"TmNew"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs := []rpc.Transaction{}
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case batch, ok := <-rescan.Txs():
+			txs = append(txs, batch...)
+			done = !ok
+		case <-timeout:
+			t.Fatal("the rescan did not finish")
+		}
+	}
+	if err := rescan.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 2 || txs[0].Txid != deposit.Txid || txs[1].Txid != spend.Txid {
+		t.Fatalf("expected the deposit and its spend, got %+v", txs)
+	}
+	progress := rescan.Progress()
+	if !progress.Done || progress.Current != 4 || progress.Txs != 2 || progress.Error != "" {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+}
+
+func TestSynchronizer_RescanCancel(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(10 * defaultRescanBatch)
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	last := uint64(10*defaultRescanBatch - 2)
+	receiveTxs(t, txChan, int(last)+1)
+	for synchronizer.GetHistoryOrder().LastTxBlockOrder < last {
+		time.Sleep(time.Millisecond)
+	}
+
+	// nothing is found, the rescan only stops on the cancel
+	rescan, err := synchronizer.Rescan(0, last, []string{"TmNew"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rescan.Cancel()
+	if err := rescan.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the rescan to be canceled, got %v", err)
+	}
+	if progress := rescan.Progress(); progress.Current > last {
+		t.Fatalf("the rescan ran to the end %+v", progress)
+	}
+}
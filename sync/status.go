@@ -0,0 +1,141 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/bCoder778/log"
+	"time"
+)
+
+const defaultRateWindow = 10 * time.Second
+
+// Status is a snapshot of the synchronization
+type Status struct {
+	// the last processed block
+	Order uint64 `json:"order"`
+	// the main order of the node and how far the last processed block is behind it
+	MainOrder uint64 `json:"mainOrder"`
+	Lag       uint64 `json:"lag"`
+	// processed blocks per second over the last 10 seconds
+	BlocksPerSecond float64 `json:"blocksPerSecond"`
+	// the last failure of the sync loop and the consecutive failures
+	LastError     string    `json:"lastError"`
+	LastErrorTime time.Time `json:"lastErrorTime"`
+	Retries       int       `json:"retries"`
+	// the sync loop waits for the next block to be confirmed
+	Waiting bool `json:"waiting"`
+}
+
+// syncStatus is guarded by the mutex of the synchronizer
+type syncStatus struct {
+	mainOrder   uint64
+	lastErr     string
+	lastErrTime time.Time
+	waiting     bool
+	rate        rateMeter
+	// the order the sync loop is at and when it processed a block
+	current    uint64
+	progressAt time.Time
+}
+
+// Status returns a snapshot of the synchronization, it is safe for concurrent use
+func (s *Synchronizer) Status() *Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	status := &Status{
+		Order:           s.lastOrder,
+		MainOrder:       s.status.mainOrder,
+		BlocksPerSecond: s.status.rate.rate(time.Now()),
+		LastError:       s.status.lastErr,
+		LastErrorTime:   s.status.lastErrTime,
+		Retries:         s.retries,
+		Waiting:         s.status.waiting,
+	}
+	if status.MainOrder > status.Order {
+		status.Lag = status.MainOrder - status.Order
+	}
+	return status
+}
+
+func (s *Synchronizer) setMainOrder(order uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status.mainOrder = order
+}
+
+func (s *Synchronizer) setCurrent(order uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status.current = order
+}
+
+func (s *Synchronizer) setWaiting(waiting bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status.waiting = waiting
+}
+
+// watchMainOrder updates the main order of the node every PollInterval
+func (s *Synchronizer) watchMainOrder() {
+	ctx := rpc.WithPriority(s.ctx, rpc.PriorityBackground)
+	t := time.NewTicker(s.opt.PollInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-t.C:
+		}
+		nodeInfo, err := s.rpcClient.GetNodeInfoContext(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Debugf("failed to get node info, %s", err.Error())
+			}
+			continue
+		}
+		s.setMainOrder(nodeInfo.GraphState.Mainorder)
+	}
+}
+
+// rateMeter counts events over a sliding window, with one sample per second
+type rateMeter struct {
+	total   uint64
+	samples []rateSample
+}
+
+type rateSample struct {
+	at    time.Time
+	total uint64
+}
+
+func (m *rateMeter) add(now time.Time) {
+	if len(m.samples) == 0 || now.Sub(m.samples[len(m.samples)-1].at) >= time.Second {
+		m.samples = append(m.samples, rateSample{at: now, total: m.total})
+	}
+	m.total++
+	m.trim(now)
+}
+
+func (m *rateMeter) rate(now time.Time) float64 {
+	m.trim(now)
+	if len(m.samples) == 0 {
+		return 0
+	}
+	elapsed := now.Sub(m.samples[0].at)
+	if elapsed < time.Second {
+		elapsed = time.Second
+	}
+	return float64(m.total-m.samples[0].total) / elapsed.Seconds()
+}
+
+func (m *rateMeter) trim(now time.Time) {
+	i := 0
+	for i < len(m.samples) && now.Sub(m.samples[i].at) > defaultRateWindow {
+		i++
+	}
+	m.samples = m.samples[i:]
+}
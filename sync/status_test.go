@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func waitStatus(t *testing.T, synchronizer *Synchronizer, ok func(*Status) bool) *Status {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		status := synchronizer.Status()
+		if ok(status) {
+			return status
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("unexpected status %+v", status)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestSynchronizer_Status(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(5)
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	receiveTxs(t, txChan, 4)
+
+	status := waitStatus(t, synchronizer, func(status *Status) bool {
+		return status.Waiting && status.Order == 3
+	})
+	if status.MainOrder != 5 || status.Lag != 2 || status.BlocksPerSecond <= 0 || status.Retries != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	node.InjectError("getBlockByOrder", -1, &rpc.Error{Code: -32603, Message: "internal error"})
+	node.Mine(1)
+	status = waitStatus(t, synchronizer, func(status *Status) bool {
+		return status.Retries > 0 && status.MainOrder == 6
+	})
+	if !strings.Contains(status.LastError, "internal error") || status.LastErrorTime.IsZero() || status.Lag != 3 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestRateMeter(t *testing.T) {
+	m := &rateMeter{}
+	now := time.Now()
+	for i := 0; i < 20; i++ {
+		m.add(now.Add(time.Duration(i) * 500 * time.Millisecond))
+	}
+	now = now.Add(10 * time.Second)
+	if rate := m.rate(now); rate < 1.5 || rate > 2.5 {
+		t.Fatalf("expected about 2 blocks per second, got %f", rate)
+	}
+	if rate := m.rate(now.Add(time.Minute)); rate != 0 {
+		t.Fatalf("expected no blocks, got %f", rate)
+	}
+}
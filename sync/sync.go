@@ -1,28 +1,64 @@
 package sync
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Qitmeer/exchange-lib/rpc"
 	"github.com/bCoder778/log"
+	sync2 "sync"
 	"time"
 )
 
 const (
 	defaultHost                 = "127.0.0.1:1234"
+	defaultHealthCheckInterval  = 30 * time.Second
+	defaultMaxRetryBackoff      = 30 * time.Second
+	defaultPollInterval         = 3 * time.Second
 	defaultTxChLen              = 100
 	defaultRepeatCount          = 5
 	DefaultCoinBaseThreshold    = 720
 	defaultTransactionThreshold = 10
+	// the longest wait for a notification before polling anyway
+	defaultNotifyTimeout = time.Minute
 )
 
 type Synchronizer struct {
-	rpcClient             *rpc.Client
-	opt                   *Options
-	threshold             *threshold
-	txChannel             chan []rpc.Transaction
-	stopSyncTxCh          chan bool
-	curTxBlockOrder       uint64
+	rpcClient       *rpc.Client
+	notifier        *rpc.NotifyClient
+	node            *Handshake
+	mutex           sync2.RWMutex
+	opt             *Options
+	threshold       *threshold
+	txChannel       chan []rpc.Transaction
+	events          chan *BlockEvent
+	errs            chan *SyncError
+	ctx             context.Context
+	cancel          context.CancelFunc
+	curTxBlockOrder uint64
+	// hashes of the processed orders, the last processed block
+	hashes    *hashWindow
+	lastOrder uint64
+	lastHash  string
+	// the last block whose transactions were handed to the channel of Start
+	forwarding bool
+	sentOrder  uint64
+	sentHash   string
+	// the last acknowledged block, see Ack
+	ackMutex   sync2.Mutex
+	checkpoint *HistoryOrder
+	// goroutines of the synchronizer, see Wait
+	wg sync2.WaitGroup
+	// blocks fetched ahead, nil without PrefetchWorkers
+	prefetch *prefetcher
+	// recently processed blocks, nil without ReorgWindow
+	reorg *reorgWindow
+	// transactions sent as confirming, nil without ConfirmationProgress
+	progress chan *TxProgress
+	tracked  map[string]*trackedTx
+	// consecutive failures of the sync loop, see Status
+	retries int
+	status  syncStatus
 }
 
 type Options struct {
@@ -31,13 +67,66 @@ type Options struct {
 	RpcUser string
 	RpcPwd  string
 	Https   bool
+	// more nodes to fail over to, transactions are sent to all of them
+	RpcAddrs []string
+	// how often the nodes are probed when there are several of them
+	HealthCheckInterval time.Duration
+	// Rpc timeouts, zero means no limit
+	RpcConnectTimeout time.Duration
+	RpcReadTimeout    time.Duration
+	// Rpc tls options, see rpc.RpcConfig
+	RpcCAFile     string
+	RpcCertFile   string
+	RpcKeyFile    string
+	RpcServerName string
+	RpcSkipVerify bool
 	// tx channel length
 	TxChLen uint
+	// Retry is used for the idempotent rpc calls and for the delay of the
+	// sync loop after a failure, the sync loop itself never gives up
+	Retry *rpc.RetryPolicy
+	// Notify subscribes to the block notifications of the node over a websocket,
+	// the synchronizer polls every PollInterval while the socket is down
+	Notify       bool
+	PollInterval time.Duration
+	// Rpc rate and concurrency limits, see rpc.RpcConfig
+	RpcRateLimit   float64
+	RpcRateBurst   int
+	RpcMaxInFlight int
+	// Network of the node, e.g. "mainnet" or "testnet", the node and the addresses
+	// are checked against it when it is set
+	Network string
+	// PrefetchWorkers is the number of confirmed blocks fetched ahead while
+	// catching up, the blocks are still processed in order. Zero or one
+	// fetches one block at a time.
+	PrefetchWorkers int
+	// ReorgWindow is the number of processed blocks checked again for changes of
+	// their order, validity or color while waiting for new blocks, the changes are
+	// sent as rollback events by StartEvents. Start refuses it. Zero disables it.
+	ReorgWindow int
+	// Watch selects the transactions which are sent, all of them are sent without it
+	Watch *WatchSet
+	// ConfirmationProgress sends the transactions of unconfirmed blocks with their
+	// confirmations until they are final or orphaned, see Synchronizer.ConfirmationProgress
+	ConfirmationProgress bool
+	// StuckTimeout is how long the sync loop may process no block, apart from
+	// waiting for confirmations, before it is reported to Errors. Default 10 minutes, also used when it is negative.
+	StuckTimeout time.Duration
+	// MinNodeVersion is the oldest build version of the node to sync from, e.g. "0.10.5"
+	MinNodeVersion string
+	// Checkpoint keeps the blocks acknowledged with Ack, Start resumes from it
+	Checkpoint CheckpointStore
+	// RpcWrapTransport wraps the rpc transport, see rpc.Recorder and rpc.Replay
+	RpcWrapTransport func(rpc.Transport) rpc.Transport
 }
 
 type HistoryOrder struct {
-	LastTxBlockOrder       uint64
-	Confirmations          uint64
+	LastTxBlockOrder uint64
+	Confirmations    uint64
+	// LastBlockHash is the hash of the block at LastTxBlockOrder, Start refuses
+	// to resume if the node has another block there. With Options.ReorgWindow the
+	// block is rolled back instead. Empty skips the check.
+	LastBlockHash string
 }
 
 func NewSynchronizer(opt *Options) *Synchronizer {
@@ -47,60 +136,230 @@ func NewSynchronizer(opt *Options) *Synchronizer {
 	if opt.TxChLen == 0 {
 		opt.TxChLen = defaultTxChLen
 	}
+	if opt.HealthCheckInterval == 0 {
+		opt.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if opt.Retry == nil {
+		opt.Retry = DefaultRetryPolicy()
+	}
+	if opt.PollInterval == 0 {
+		opt.PollInterval = defaultPollInterval
+	}
+	if opt.StuckTimeout <= 0 {
+		opt.StuckTimeout = defaultStuckTimeout
+	}
 
-	client := rpc.NewClient(&rpc.RpcConfig{
+	rpcCfg := &rpc.RpcConfig{
 		Address: opt.RpcAddr,
 		User:    opt.RpcUser,
 		Pwd:     opt.RpcPwd,
 		Https:   opt.Https,
-	})
-	return &Synchronizer{
-		rpcClient:          client,
-		opt:                opt,
-		txChannel:          make(chan []rpc.Transaction, opt.TxChLen),
-		stopSyncTxCh:       make(chan bool),
+
+		Addresses:      opt.RpcAddrs,
+		ConnectTimeout: opt.RpcConnectTimeout,
+		ReadTimeout:    opt.RpcReadTimeout,
+
+		CAFile:             opt.RpcCAFile,
+		CertFile:           opt.RpcCertFile,
+		KeyFile:            opt.RpcKeyFile,
+		ServerName:         opt.RpcServerName,
+		InsecureSkipVerify: opt.RpcSkipVerify,
+		Retry:              opt.Retry,
+		WrapTransport:      opt.RpcWrapTransport,
+		RateLimit:          opt.RpcRateLimit,
+		RateBurst:          opt.RpcRateBurst,
+		MaxInFlight:        opt.RpcMaxInFlight,
+	}
+	var notifier *rpc.NotifyClient
+	if opt.Notify {
+		notifier = rpc.NewNotifyClient(rpcCfg)
+	}
+	var prefetch *prefetcher
+	if opt.PrefetchWorkers > 1 {
+		prefetch = newPrefetcher(opt.PrefetchWorkers)
+	}
+	var tracked map[string]*trackedTx
+	if opt.ConfirmationProgress {
+		tracked = map[string]*trackedTx{}
+	}
+	var reorg *reorgWindow
+	if opt.ReorgWindow > 0 {
+		reorg = &reorgWindow{size: opt.ReorgWindow}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Synchronizer{
+		prefetch:  prefetch,
+		reorg:     reorg,
+		notifier:  notifier,
+		opt:       opt,
+		txChannel: make(chan []rpc.Transaction, opt.TxChLen),
+		events:    make(chan *BlockEvent, opt.TxChLen),
+		errs:      make(chan *SyncError, defaultErrChLen),
+		progress:  make(chan *TxProgress, opt.TxChLen),
+		tracked:   tracked,
+		ctx:       ctx,
+		cancel:    cancel,
+		hashes:    newHashWindow(defaultHashWindow),
 		threshold: &threshold{
 			coinBaseThreshold:    DefaultCoinBaseThreshold,
 			transactionThreshold: defaultTransactionThreshold,
 		},
 	}
+	if len(opt.RpcAddrs) != 0 {
+		// the nodes of the pool are only used once they passed the checks of the handshake
+		rpcCfg.VerifyNode = s.verifyNode
+	}
+	s.rpcClient = rpc.NewClient(rpcCfg)
+	return s
 }
 
 // start syncing at 0
 // or start syncing at last stop return id
+// The transactions of a block are sent at once, blocks without transactions are not sent,
+// see StartEvents for all the blocks. With Options.ReorgWindow it returns ErrReorgWindow.
 func (s *Synchronizer) Start(info *HistoryOrder) (<-chan []rpc.Transaction, error) {
-	if err := s.setThreshold(info.Confirmations); err != nil {
-		return nil, fmt.Errorf("failed to set threshold %s", err.Error())
+	if s.reorg != nil {
+		return nil, ErrReorgWindow
 	}
-
-	go s.startSync(info)
+	events, err := s.StartEvents(info)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.forwarding = true
+	s.mutex.Unlock()
+	s.goWait(func() {
+		s.forwardTxs(events)
+	})
 
 	return s.txChannel, nil
 }
 
-// use the return value as the parameter for the next startup
-func (s *Synchronizer) Stop() {
-	s.stopSyncTxCh <- true
-	//s.stopSyncCoinBaseCh <- true
+// Run starts syncing like Start until ctx is done. Once the synchronizer stopped
+// the transaction channel is closed, the batches left in it can still be taken.
+func (s *Synchronizer) Run(ctx context.Context, info *HistoryOrder) (<-chan []rpc.Transaction, error) {
+	s.goWait(func() {
+		select {
+		case <-ctx.Done():
+			s.cancel()
+		case <-s.ctx.Done():
+		}
+	})
+	txChan, err := s.Start(info)
+	if err != nil {
+		s.cancel()
+		return nil, err
+	}
+	return txChan, nil
 }
 
+// Stop cancels the sync loop together with any rpc request in flight, waits for it
+// and returns the last block handed to the consumer. With Start the order is only
+// safe to persist once the batches left in the closed channel have been taken,
+// with StartEvents once the events left have been taken.
+func (s *Synchronizer) Stop() *HistoryOrder {
+	s.cancel()
+	s.Wait()
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if !s.forwarding {
+		return &HistoryOrder{
+			LastTxBlockOrder: s.lastOrder,
+			LastBlockHash:    s.lastHash,
+		}
+	}
+	return &HistoryOrder{
+		LastTxBlockOrder: s.sentOrder,
+		LastBlockHash:    s.sentHash,
+	}
+}
+
+// Wait blocks until every goroutine of the synchronizer returned,
+// after Stop or after the context of Run is done
+func (s *Synchronizer) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Synchronizer) goWait(f func()) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		f()
+	}()
+}
+
+// CurrentNode returns the address of the node the synchronizer reads from
+func (s *Synchronizer) CurrentNode() string {
+	return s.rpcClient.CurrentNode()
+}
+
+// Nodes returns the state of every configured node
+func (s *Synchronizer) Nodes() []rpc.NodeStatus {
+	return s.rpcClient.Nodes()
+}
+
+// GetHistoryOrder returns the last processed block. Its transactions may not have been
+// taken from the channel yet, use Ack and Options.Checkpoint to resume without skipping them.
 func (s *Synchronizer) GetHistoryOrder() *HistoryOrder {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
 	return &HistoryOrder{
-		LastTxBlockOrder:       s.curTxBlockOrder,
+		LastTxBlockOrder: s.lastOrder,
+		LastBlockHash:    s.lastHash,
 	}
 }
 
-func (s *Synchronizer) startSync(hisOrder *HistoryOrder) {
-	s.curTxBlockOrder = hisOrder.LastTxBlockOrder
-	if s.curTxBlockOrder >= defaultRepeatCount {
-		s.curTxBlockOrder -= defaultRepeatCount
-	} else {
-		s.curTxBlockOrder = 0
+// startSync starts the sync loop after the last block of hisOrder, replaced tells
+// that the node has another block there which has to be rolled back first
+func (s *Synchronizer) startSync(hisOrder *HistoryOrder, replaced bool) error {
+	s.mutex.Lock()
+	s.lastOrder, s.lastHash = hisOrder.LastTxBlockOrder, hisOrder.LastBlockHash
+	s.sentOrder, s.sentHash = s.lastOrder, s.lastHash
+	if hisOrder.LastBlockHash != "" {
+		s.hashes.add(hisOrder.LastTxBlockOrder, hisOrder.LastBlockHash)
 	}
+	s.mutex.Unlock()
 
+	s.curTxBlockOrder = hisOrder.LastTxBlockOrder
+	// without the reorg window the last blocks are synchronized again
+	// in case they changed, with it they are checked for changes
+	if s.reorg == nil || hisOrder.LastBlockHash == "" {
+		if s.curTxBlockOrder >= defaultRepeatCount {
+			s.curTxBlockOrder -= defaultRepeatCount
+		} else {
+			s.curTxBlockOrder = 0
+		}
+	}
+	if err := s.preloadReorgWindow(s.curTxBlockOrder); err != nil {
+		return err
+	}
+	if replaced {
+		if err := s.loadReplacedBlock(hisOrder); err != nil {
+			return err
+		}
+	}
 
-	go s.SyncTxs()
+	s.mutex.Lock()
+	s.status.progressAt = time.Now()
+	s.mutex.Unlock()
+	s.goWait(func() {
+		// the loop and the stuck watcher are the only senders
+		defer close(s.events)
+		stuck := make(chan struct{})
+		go func() {
+			defer close(stuck)
+			s.watchStuck()
+		}()
+		s.SyncTxs()
+		<-stuck
+		close(s.errs)
+		close(s.progress)
+	})
 	//go s.SyncCoinBaseTx()
+	return nil
 }
 
 func (s *Synchronizer) SyncTxs() {
@@ -110,34 +369,151 @@ func (s *Synchronizer) SyncTxs() {
 func (s *Synchronizer) requestTxs() {
 	for {
 		select {
-		case _ = <-s.stopSyncTxCh:
+		case <-s.ctx.Done():
 			log.Infof("stop sync tx")
 			return
 		default:
-			block, err := s.rpcClient.GetBlockByOrder(s.curTxBlockOrder)
-			if err != nil {
-				time.Sleep(time.Second * 30)
+			s.setCurrent(s.curTxBlockOrder)
+			res := s.fetch(s.curTxBlockOrder)
+			if res.err != nil {
+				s.retry(&SyncError{Kind: ErrorFetch, Order: s.curTxBlockOrder, Err: res.err})
+				break
+			}
+			block := res.block
+			if err := s.verifyBlock(block); err != nil {
+				// the block may follow a processed block which was reorganized,
+				// then it is rolled back and the chain continues from there
+				if s.reorg != nil && errors.Is(err, ErrChainMismatch) {
+					s.recheck()
+					if s.curTxBlockOrder != block.Order {
+						break
+					}
+				}
+				log.Errorf("stop at block %d, %s", block.Order, err.Error())
+				s.retry(&SyncError{Kind: ErrorChain, Order: block.Order, Hash: block.Hash, Err: err})
 				break
 			}
 			if s.isTxConfirmed(block) {
+				if res.colorErr != nil {
+					s.retry(&SyncError{Kind: ErrorColor, Order: block.Order, Hash: block.Hash, Err: res.colorErr})
+					break
+				}
+				isBlue := res.isBlue
+				var txs []rpc.Transaction
 				if block.Txsvalid {
-					if isBlue, err := s.IsCoinBaseUsable(block); err == nil {
-						txs := s.getConfirmedTx(block, isBlue)
-						if len(txs) != 0 {
-							s.txChannel <- txs
-						}
-						s.curTxBlockOrder++
-					} else {
-						time.Sleep(time.Second * 30)
-					}
-				}else{
-					s.curTxBlockOrder++
+					txs = s.filterTxs(s.getConfirmedTx(block, isBlue))
+				}
+				s.processed(block, isBlue, txs)
+			} else {
+				s.recheck()
+				// nothing was rolled back
+				if s.curTxBlockOrder == block.Order {
+					s.trackUnconfirmed(block)
+					s.waitBlock()
 				}
 			}
 		}
 	}
 }
 
+func (s *Synchronizer) getBlock(order uint64) (*rpc.Block, error) {
+	return s.rpcClient.GetBlockByOrderContext(s.ctx, order)
+}
+
+func (s *Synchronizer) verifyBlock(block *rpc.Block) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.hashes.verify(block)
+}
+
+// processed sends the event of block, records its hash and moves on to the next order
+func (s *Synchronizer) processed(block *rpc.Block, isBlue bool, txs []rpc.Transaction) {
+	ok := s.emit(&BlockEvent{
+		Order:     block.Order,
+		Hash:      block.Hash,
+		Height:    block.Height,
+		Timestamp: block.Timestamp,
+		Blue:      isBlue,
+		Txsvalid:  block.Txsvalid,
+		Txs:       txs,
+	})
+	if !ok {
+		return
+	}
+	s.finalize(block, txs)
+	if s.reorg != nil {
+		s.reorg.add(&sentBlock{order: block.Order, hash: block.Hash, txsvalid: block.Txsvalid, blue: isBlue, txs: txs})
+	}
+	s.mutex.Lock()
+	s.hashes.add(block.Order, block.Hash)
+	s.lastOrder, s.lastHash = block.Order, block.Hash
+	s.retries = 0
+	s.status.progressAt = time.Now()
+	s.status.rate.add(s.status.progressAt)
+	s.mutex.Unlock()
+
+	s.curTxBlockOrder++
+}
+
+// waitBlock waits for a new block before the confirmations are checked again.
+// It wakes up on the notifications of the node and polls while the socket is down.
+// The notifications queued up while catching up wake it once, they are dropped
+// after waking rather than before waiting so that a block announced during
+// the last check is not missed.
+func (s *Synchronizer) waitBlock() {
+	wait := s.opt.PollInterval
+	var blocks <-chan *rpc.BlockConnected
+	if s.notifier != nil && s.notifier.Connected() {
+		blocks = s.notifier.Blocks()
+		wait = defaultNotifyTimeout
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+
+	s.setWaiting(true)
+	defer s.setWaiting(false)
+	select {
+	case <-s.ctx.Done():
+	case <-blocks:
+		drainBlocks(blocks)
+	case <-t.C:
+	}
+}
+
+func drainBlocks(blocks <-chan *rpc.BlockConnected) {
+	for {
+		select {
+		case <-blocks:
+		default:
+			return
+		}
+	}
+}
+
+// DefaultRetryPolicy is the rpc default policy with the delay
+// of the sync loop capped at 30 seconds
+func DefaultRetryPolicy() *rpc.RetryPolicy {
+	policy := rpc.DefaultRetryPolicy()
+	policy.MaxBackoff = defaultMaxRetryBackoff
+	return policy
+}
+
+// retry waits before trying again after a failure,
+// the delay grows with the consecutive failures
+func (s *Synchronizer) retry(err *SyncError) {
+	s.report(err)
+	s.mutex.Lock()
+	s.retries++
+	retries := s.retries
+	if s.ctx.Err() == nil {
+		s.status.lastErr, s.status.lastErrTime = err.Error(), err.Time
+	}
+	s.mutex.Unlock()
+
+	s.opt.Retry.Wait(s.ctx, retries)
+}
+
 func (s *Synchronizer) isBlockConfirmed(block *rpc.Block) bool {
 	return block.Confirmations > s.threshold.coinBaseThreshold
 }
@@ -147,7 +523,7 @@ func (s *Synchronizer) isTxConfirmed(block *rpc.Block) bool {
 }
 
 func (s *Synchronizer) IsCoinBaseUsable(block *rpc.Block) (bool, error) {
-	color, err := s.rpcClient.IsBlue(block.Hash)
+	color, err := s.rpcClient.IsBlueContext(s.ctx, block.Hash)
 	if err != nil {
 		return false, err
 	}
@@ -157,35 +533,37 @@ func (s *Synchronizer) IsCoinBaseUsable(block *rpc.Block) (bool, error) {
 	case 1:
 		return true, nil
 	}
-	return false, errors.New("unkonwn")
+	return false, fmt.Errorf("%w %d", ErrUnknownColor, color)
 }
 
 func (s *Synchronizer) SendTx(raw string) (string, error) {
 	return s.rpcClient.SendTransaction(raw)
 }
 
+func (s *Synchronizer) SendTxContext(ctx context.Context, raw string) (string, error) {
+	return s.rpcClient.SendTransactionContext(ctx, raw)
+}
+
 func (s *Synchronizer) GetTx(txId string) (*rpc.Transaction, error) {
 	return s.rpcClient.GetTransaction(txId)
 }
 
+func (s *Synchronizer) GetTxContext(ctx context.Context, txId string) (*rpc.Transaction, error) {
+	return s.rpcClient.GetTransactionContext(ctx, txId)
+}
+
 type threshold struct {
 	coinBaseThreshold    uint32
 	transactionThreshold uint32
 }
 
-func (s *Synchronizer) setThreshold(confirmations uint64) error {
-	nodeInfo, err := s.rpcClient.GetNodeInfo()
-	if err != nil {
-		return err
-	}
+func (s *Synchronizer) setThreshold(nodeInfo *rpc.NodeInfo, confirmations uint64) {
 	if confirmations != 0 {
 		s.threshold.transactionThreshold = uint32(confirmations)
 	} else {
 		s.threshold.transactionThreshold = nodeInfo.Confirmations
 	}
 	s.threshold.coinBaseThreshold = nodeInfo.Coinbasematurity
-
-	return nil
 }
 
 func (s *Synchronizer) getConfirmedTx(block *rpc.Block, isBlue bool) []rpc.Transaction {
@@ -195,7 +573,7 @@ func (s *Synchronizer) getConfirmedTx(block *rpc.Block, isBlue bool) []rpc.Trans
 			continue
 		}
 		tx.IsCoinBase = isCoinBase(&tx)
-		if tx.IsCoinBase && !isBlue{
+		if tx.IsCoinBase && !isBlue {
 			continue
 		}
 		tx.BlockOrder = block.Order
@@ -0,0 +1,39 @@
+package sync
+
+import (
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"testing"
+	"time"
+)
+
+func benchmarkInitialSync(b *testing.B, workers int) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Latency = time.Millisecond
+	const blocks = 200
+	node.Mine(blocks)
+
+	for i := 0; i < b.N; i++ {
+		opt := newTestOptions(node)
+		opt.PrefetchWorkers = workers
+		opt.TxChLen = blocks
+		synchronizer := NewSynchronizer(opt)
+		txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+		if err != nil {
+			b.Fatal(err)
+		}
+		// every block but the last two is confirmed and has a coinbase
+		for received := 0; received < blocks-1; {
+			received += len(<-txChan)
+		}
+		synchronizer.Stop()
+	}
+}
+
+func BenchmarkSynchronizer_InitialSync(b *testing.B) {
+	benchmarkInitialSync(b, 0)
+}
+
+func BenchmarkSynchronizer_InitialSyncPrefetch(b *testing.B) {
+	benchmarkInitialSync(b, 8)
+}
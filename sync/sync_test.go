@@ -1,8 +1,13 @@
 package sync
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
 	"github.com/Qitmeer/exchange-lib/uxto"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -16,7 +21,7 @@ func TestSynchronizer_Start(t *testing.T) {
 		TxChLen: 100,
 	}
 	synchronizer := NewSynchronizer(opt)
-	txChan, err := synchronizer.Start(&HistoryOrder{0,  10})
+	txChan, err := synchronizer.Start(&HistoryOrder{LastTxBlockOrder: 0, Confirmations: 10})
 	if err != nil {
 		fmt.Printf(err.Error())
 		return
@@ -43,3 +48,263 @@ func TestSynchronizer_Start(t *testing.T) {
 	}()
 	time.Sleep(1000 * time.Second)
 }
+
+func newTestSynchronizer(node *rpctest.Node) *Synchronizer {
+	return NewSynchronizer(newTestOptions(node))
+}
+
+func newTestOptions(node *rpctest.Node) *Options {
+	return &Options{
+		RpcAddr:      node.Addr(),
+		RpcUser:      "test",
+		RpcPwd:       "test",
+		PollInterval: 10 * time.Millisecond,
+		Retry: &rpc.RetryPolicy{
+			MaxAttempts:    1,
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     10 * time.Millisecond,
+		},
+	}
+}
+
+// receive passes the values of ch, a receiving channel, to accept until it returns
+// true. The test fails when ch is closed before or after 5 seconds.
+func receive(t *testing.T, ch interface{}, accept func(v interface{}) bool) {
+	t.Helper()
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch)},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(time.After(5 * time.Second))},
+	}
+	for {
+		chosen, v, ok := reflect.Select(cases)
+		if chosen == 1 {
+			t.Fatalf("timed out receiving from %T", ch)
+		}
+		if !ok {
+			t.Fatalf("%T was closed", ch)
+		}
+		if accept(v.Interface()) {
+			return
+		}
+	}
+}
+
+func receiveTxs(t *testing.T, txChan <-chan []rpc.Transaction, count int) []rpc.Transaction {
+	t.Helper()
+	txs := []rpc.Transaction{}
+	receive(t, txChan, func(v interface{}) bool {
+		txs = append(txs, v.([]rpc.Transaction)...)
+		return len(txs) >= count
+	})
+	return txs
+}
+
+func TestSynchronizer_ConfirmedTxs(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)})
+	blk := node.MineBlock(deposit)
+	node.Mine(2)
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+
+	// the genesis coinbase is the only confirmed transaction so far
+	txs := receiveTxs(t, txChan, 1)
+	if txs[0].BlockOrder != 0 || !txs[0].IsCoinBase {
+		t.Fatalf("unexpected transaction %+v", txs[0])
+	}
+	select {
+	case txs := <-txChan:
+		t.Fatalf("unconfirmed transactions were sent %+v", txs)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	node.Mine(1)
+	txs = receiveTxs(t, txChan, 2)
+	if txs[1].Txid != deposit.Txid || txs[1].BlockOrder != blk.Order {
+		t.Fatalf("expected deposit of block %d, got %+v", blk.Order, txs[1])
+	}
+	utxos := uxto.GetUxtos(&txs[1])
+	if len(utxos) != 1 || utxos[0].Address != "TmAddress" || utxos[0].Amount != 100 {
+		t.Fatalf("unexpected utxos %+v", utxos)
+	}
+}
+
+func TestSynchronizer_SkipRedCoinbase(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	red := node.MineBlock()
+	node.SetBlue(red.Hash, false)
+	node.Mine(3)
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+
+	txs := receiveTxs(t, txChan, 2)
+	for _, tx := range txs {
+		if tx.BlockOrder == red.Order {
+			t.Fatalf("coinbase of a red block was sent")
+		}
+	}
+}
+
+func TestSynchronizer_DuplicateTx(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)})
+	node.MineBlock(deposit)
+	dup := node.MineBlock(deposit)
+	node.Mine(2)
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+
+	// the coinbases of orders 0 to 2 and the deposit, once
+	txs := receiveTxs(t, txChan, 4)
+	if len(txs) != 4 {
+		t.Fatalf("expected 4 transactions, got %d", len(txs))
+	}
+	for _, tx := range txs {
+		if tx.Txid == deposit.Txid && tx.BlockOrder == dup.Order {
+			t.Fatalf("the duplicate of the deposit was sent")
+		}
+	}
+}
+
+func TestSynchronizer_RetryAfterError(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(3)
+	node.InjectError("getBlockByOrder", 3, &rpc.Error{Code: -32603, Message: "internal error"})
+
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+
+	receiveTxs(t, txChan, 2)
+	if calls := node.Calls("getBlockByOrder"); calls < 5 {
+		t.Fatalf("expected the failed calls to be retried, got %d calls", calls)
+	}
+}
+
+func TestSynchronizer_Replay(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.MineBlock(rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmAddress", 100)}))
+	node.Mine(3)
+
+	path := filepath.Join(t.TempDir(), "rpc.record")
+	recorder, err := rpc.OpenRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := newTestOptions(node)
+	opt.RpcWrapTransport = recorder.Wrap
+	synchronizer := NewSynchronizer(opt)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := receiveTxs(t, txChan, 3)
+	synchronizer.Stop()
+	recorder.Close()
+	node.Close()
+
+	replay, err := rpc.LoadReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt = newTestOptions(node)
+	opt.RpcWrapTransport = replay.Wrap
+	synchronizer = NewSynchronizer(opt)
+	txChan, err = synchronizer.Start(&HistoryOrder{Confirmations: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	replayed := receiveTxs(t, txChan, 3)
+	for i := range recorded {
+		if !reflect.DeepEqual(uxto.GetUxtos(&recorded[i]), uxto.GetUxtos(&replayed[i])) {
+			t.Fatalf("replayed utxos of %s differ", recorded[i].Txid)
+		}
+	}
+}
+
+func TestSynchronizer_StopWithFullChannel(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(20)
+
+	opt := newTestOptions(node)
+	opt.TxChLen = 1
+	synchronizer := NewSynchronizer(opt)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nobody takes the transactions, the loop blocks on the channel
+	time.Sleep(100 * time.Millisecond)
+
+	stopped := make(chan *HistoryOrder)
+	go func() {
+		stopped <- synchronizer.Stop()
+	}()
+	var history *HistoryOrder
+	select {
+	case history = <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stop is blocked")
+	}
+
+	var last uint64
+	for txs := range txChan {
+		last = txs[len(txs)-1].BlockOrder
+	}
+	if history.LastTxBlockOrder != last || history.LastBlockHash != node.Block(last).Hash {
+		t.Fatalf("expected to stop at block %d, got %+v", last, history)
+	}
+}
+
+func TestSynchronizer_RunUntilCanceled(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	synchronizer := newTestSynchronizer(node)
+	txChan, err := synchronizer.Run(ctx, &HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiveTxs(t, txChan, 2)
+
+	cancel()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-txChan:
+			if !ok {
+				synchronizer.Wait()
+				return
+			}
+		case <-timeout:
+			t.Fatal("the transaction channel was not closed")
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package sync
+
+import (
+	"encoding/binary"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"hash/fnv"
+	"math"
+	sync2 "sync"
+)
+
+const (
+	// the false positive rates NewBloomFilter accepts, others are clamped to them
+	minBloomFPRate = 1e-6
+	maxBloomFPRate = 0.5
+)
+
+// WatchSet selects the outputs sent by the synchronizer by their address, their
+// script hex or a bloom filter over both. It is safe to update while syncing.
+type WatchSet struct {
+	mutex     sync2.RWMutex
+	addresses map[string]bool
+	scripts   map[string]bool
+	filter    *BloomFilter
+	// the unspent outputs sent so far, their spends are sent as well
+	outputs map[string]bool
+}
+
+func NewWatchSet() *WatchSet {
+	return &WatchSet{
+		addresses: map[string]bool{},
+		scripts:   map[string]bool{},
+		outputs:   map[string]bool{},
+	}
+}
+
+func (w *WatchSet) AddAddress(addresses ...string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, address := range addresses {
+		w.addresses[address] = true
+	}
+}
+
+func (w *WatchSet) RemoveAddress(addresses ...string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, address := range addresses {
+		delete(w.addresses, address)
+	}
+}
+
+// AddScript watches the outputs with the script hex, e.g. "76a914...88ac"
+func (w *WatchSet) AddScript(scripts ...string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, script := range scripts {
+		w.scripts[script] = true
+	}
+}
+
+// SetFilter watches the outputs whose address or script hex is in filter,
+// nil removes the filter. The filter must not be changed after it was set.
+func (w *WatchSet) SetFilter(filter *BloomFilter) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.filter = filter
+}
+
+// AddOutput watches the spends of an output, e.g. of the unspent outputs
+// saved before a restart
+func (w *WatchSet) AddOutput(txid string, vout uint64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.outputs[outKey(txid, vout)] = true
+}
+
+// Filter returns tx with the watched outputs and the inputs spending watched outputs,
+// false if there are none. The other outputs are left empty so that the indexes of the
+// outputs stay the same. The outputs are watched for their spends from now on, the
+// outputs it spends are no longer watched.
+func (w *WatchSet) Filter(tx *rpc.Transaction) (*rpc.Transaction, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.apply(tx, true)
+}
+
+// Match reports whether tx pays to a watched output or spends one, without
+// changing the watched outputs
+func (w *WatchSet) Match(tx *rpc.Transaction) bool {
+	_, ok := w.peek(tx)
+	return ok
+}
+
+// peek returns tx as Filter does without changing the watched outputs
+func (w *WatchSet) peek(tx *rpc.Transaction) (*rpc.Transaction, bool) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	return w.apply(tx, false)
+}
+
+// apply filters tx, with update the outputs it spends and pays are unwatched and watched
+func (w *WatchSet) apply(tx *rpc.Transaction, update bool) (*rpc.Transaction, bool) {
+	matched := false
+	rs := *tx
+	rs.Vin = nil
+	rs.Vout = make([]rpc.Vout, len(tx.Vout))
+	for _, vin := range tx.Vin {
+		if vin.Coinbase != "" {
+			rs.Vin = append(rs.Vin, vin)
+		} else if key := outKey(vin.Txid, vin.Vout); w.outputs[key] {
+			rs.Vin = append(rs.Vin, vin)
+			matched = true
+			if update {
+				delete(w.outputs, key)
+			}
+		}
+	}
+	for i, vout := range tx.Vout {
+		if w.watched(&vout) {
+			rs.Vout[i] = vout
+			matched = true
+			if update {
+				w.outputs[outKey(tx.Txid, uint64(i))] = true
+			}
+		}
+	}
+	return &rs, matched
+}
+
+// revert undoes Filter for a transaction it returned whose block was rolled back,
+// its outputs are no longer watched and the outputs it spent are watched again
+func (w *WatchSet) revert(tx *rpc.Transaction) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for i := range tx.Vout {
+		delete(w.outputs, outKey(tx.Txid, uint64(i)))
+	}
+	for _, vin := range tx.Vin {
+		if vin.Coinbase == "" {
+			w.outputs[outKey(vin.Txid, vin.Vout)] = true
+		}
+	}
+}
+
+func (w *WatchSet) watched(vout *rpc.Vout) bool {
+	script := vout.ScriptPubKey.Hex
+	if w.scripts[script] || (w.filter != nil && script != "" && w.filter.Test([]byte(script))) {
+		return true
+	}
+	for _, address := range vout.ScriptPubKey.Addresses {
+		if w.addresses[address] || (w.filter != nil && w.filter.Test([]byte(address))) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTxs returns the transactions with watched outputs, all of them without a watch set
+func (s *Synchronizer) filterTxs(txs []rpc.Transaction) []rpc.Transaction {
+	if s.opt.Watch == nil {
+		return txs
+	}
+	rs := []rpc.Transaction{}
+	for i := range txs {
+		if tx, ok := s.opt.Watch.Filter(&txs[i]); ok {
+			rs = append(rs, *tx)
+		}
+	}
+	return rs
+}
+
+// BloomFilter is a probabilistic set of addresses or scripts, it may contain
+// items which were not added but never misses an added one
+type BloomFilter struct {
+	bits   []uint64
+	hashes uint32
+}
+
+// NewBloomFilter returns a filter for count items with a false positive rate of fpRate,
+// which is clamped between 0.000001 and 0.5
+func NewBloomFilter(count int, fpRate float64) *BloomFilter {
+	if count < 1 {
+		count = 1
+	}
+	if math.IsNaN(fpRate) || fpRate < minBloomFPRate {
+		fpRate = minBloomFPRate
+	}
+	if fpRate > maxBloomFPRate {
+		fpRate = maxBloomFPRate
+	}
+	size := math.Ceil(-float64(count) * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	hashes := math.Round(size / float64(count) * math.Ln2)
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &BloomFilter{
+		bits:   make([]uint64, int(size)/64+1),
+		hashes: uint32(hashes),
+	}
+}
+
+func (f *BloomFilter) Add(data []byte) {
+	h1, h2 := bloomHash(data)
+	size := uint64(len(f.bits) * 64)
+	for i := uint32(0); i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (f *BloomFilter) Test(data []byte) bool {
+	h1, h2 := bloomHash(data)
+	size := uint64(len(f.bits) * 64)
+	for i := uint32(0); i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % size
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHash returns two hashes of data for double hashing
+func bloomHash(data []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write(data)
+	h1 := h.Sum64()
+	var seed [8]byte
+	binary.LittleEndian.PutUint64(seed[:], h1)
+	h.Write(seed[:])
+	return h1, h.Sum64() | 1
+}
@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"fmt"
+	"github.com/Qitmeer/exchange-lib/rpc"
+	"github.com/Qitmeer/exchange-lib/rpctest"
+	"github.com/Qitmeer/exchange-lib/uxto"
+	"reflect"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	f := NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add([]byte(fmt.Sprintf("TmAddress%d", i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !f.Test([]byte(fmt.Sprintf("TmAddress%d", i))) {
+			t.Fatalf("address %d is missing", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.Test([]byte(fmt.Sprintf("TmOther%d", i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Fatalf("%d false positives of 10000", falsePositives)
+	}
+
+	// out of range rates are clamped
+	for _, fpRate := range []float64{0, -1, 1, 2} {
+		f := NewBloomFilter(100, fpRate)
+		f.Add([]byte("TmAddress"))
+		if !f.Test([]byte("TmAddress")) {
+			t.Fatalf("the filter of rate %v misses an added address", fpRate)
+		}
+	}
+}
+
+func TestSynchronizer_WatchSet(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmOther", 50), rpctest.Output("TmWatched", 100)})
+	node.MineBlock(deposit, rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmOther", 100)}))
+	spend := rpctest.NewTransaction([]rpc.Vin{rpctest.Input(deposit.Txid, 1), rpctest.Input("unknown", 0)}, []rpc.Vout{rpctest.Output("TmOther", 90)})
+	node.MineBlock(spend)
+	node.Mine(2)
+
+	filter := NewBloomFilter(10, 0.001)
+	filter.Add([]byte("TmFiltered"))
+	watch := NewWatchSet()
+	watch.AddAddress("TmWatched")
+	watch.SetFilter(filter)
+	opt := newTestOptions(node)
+	opt.Watch = watch
+	synchronizer := NewSynchronizer(opt)
+	txChan, err := synchronizer.Start(&HistoryOrder{Confirmations: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+
+	txs := receiveTxs(t, txChan, 2)
+	if txs[0].Txid != deposit.Txid || txs[1].Txid != spend.Txid {
+		t.Fatalf("expected the deposit and its spend, got %+v", txs)
+	}
+	utxos := uxto.GetUxtos(&txs[0])
+	if len(utxos) != 1 || utxos[0].Address != "TmWatched" || utxos[0].TxIndex != 1 {
+		t.Fatalf("expected the watched output only, got %+v", utxos)
+	}
+	spent := uxto.GetSpentTxs(&txs[1])
+	if len(spent) != 1 || spent[0].TxId != deposit.Txid || spent[0].Vout != 1 || len(uxto.GetUxtos(&txs[1])) != 0 {
+		t.Fatalf("expected the spend of the watched output only, got %+v", txs[1])
+	}
+
+	watch.AddScript(rpctest.Output("TmScript", 0).ScriptPubKey.Hex)
+	late := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmScript", 100), rpctest.Output("TmFiltered", 100)})
+	node.MineBlock(late)
+	node.Mine(2)
+	txs = receiveTxs(t, txChan, 1)
+	if txs[0].Txid != late.Txid || len(uxto.GetUxtos(&txs[0])) != 2 {
+		t.Fatalf("expected both outputs of the late deposit, got %+v", txs[0])
+	}
+}
+
+func TestWatchSet_SpentOutputs(t *testing.T) {
+	watch := NewWatchSet()
+	watch.AddAddress("TmWatched")
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmOther", 50), rpctest.Output("TmWatched", 100)})
+	spend := rpctest.NewTransaction([]rpc.Vin{rpctest.Input(deposit.Txid, 1)}, []rpc.Vout{rpctest.Output("TmOther", 90)})
+
+	filteredDeposit, ok := watch.Filter(deposit)
+	if !ok {
+		t.Fatal("expected the deposit to match")
+	}
+	filteredSpend, ok := watch.Filter(spend)
+	if !ok {
+		t.Fatal("expected the spend to match")
+	}
+	if watch.Match(spend) || len(watch.outputs) != 0 {
+		t.Fatalf("the spent output is still watched %v", watch.outputs)
+	}
+
+	// rolled back from the last block on
+	watch.revert(filteredSpend)
+	if !watch.Match(spend) {
+		t.Fatal("expected the output to be watched again once its spend was rolled back")
+	}
+	watch.revert(filteredDeposit)
+	if watch.Match(spend) || len(watch.outputs) != 0 {
+		t.Fatalf("the outputs of a rolled back deposit are still watched %v", watch.outputs)
+	}
+}
+
+func TestSynchronizer_WatchProgress(t *testing.T) {
+	node := rpctest.NewNode()
+	defer node.Close()
+	node.Mine(1)
+
+	watch := NewWatchSet()
+	watch.AddAddress("TmWatched")
+	opt := newTestOptions(node)
+	opt.Watch = watch
+	opt.ConfirmationProgress = true
+	synchronizer := NewSynchronizer(opt)
+	if _, err := synchronizer.Start(&HistoryOrder{Confirmations: 2}); err != nil {
+		t.Fatal(err)
+	}
+	defer synchronizer.Stop()
+	progress := synchronizer.ConfirmationProgress()
+
+	deposit := rpctest.NewTransaction(nil, []rpc.Vout{rpctest.Output("TmOther", 50), rpctest.Output("TmWatched", 100)})
+	node.MineBlock(deposit)
+	confirming := receiveProgress(t, progress, deposit.Txid, TxConfirming)
+	node.Mine(3)
+	final := receiveProgress(t, progress, deposit.Txid, TxFinal)
+	if !reflect.DeepEqual(confirming.Tx.Vout, final.Tx.Vout) || len(uxto.GetUxtos(confirming.Tx)) != 1 {
+		t.Fatalf("expected the watched output only, confirming %+v, final %+v", confirming.Tx.Vout, final.Tx.Vout)
+	}
+}